@@ -0,0 +1,98 @@
+// Команда webhook-replay отправляет записанные обновления Telegram (JSON-файлы)
+// на эндпоинт вебхука и проверяет, что они приняты.
+//
+// Без флага -url поднимается локальный сервер с тем же обработчиком вебхука,
+// хранилищем в памяти и записывающим мессенджером, а ответы бота выводятся в консоль:
+//
+//	go run ./cmd/webhook-replay cmd/webhook-replay/updates/*.json
+//
+// С флагом -url обновления отправляются на запущенного бота:
+//
+//	go run ./cmd/webhook-replay -url http://localhost:8443/telegram -secret change-me updates/*.json
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"telegram-bot-go/config"
+	"telegram-bot-go/handlers"
+	"telegram-bot-go/handlers/handlerstest"
+	"telegram-bot-go/pool"
+	"telegram-bot-go/storage"
+	"telegram-bot-go/webhook"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func main() {
+	url := flag.String("url", "", "адрес вебхука; если не задан, используется локальный сервер")
+	secret := flag.String("secret", "local-secret", "значение заголовка "+webhook.SecretHeader)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Укажите JSON-файлы с обновлениями")
+	}
+
+	var bot *handlerstest.RecordingMessenger
+	var workers *pool.Pool
+	target := *url
+	if target == "" {
+		bot = handlerstest.NewRecordingMessenger()
+		handlers.InitHandlers(storage.NewMemory(), &config.Config{})
+		workers = pool.New(1, func(update tgbotapi.Update) {
+			handlers.HandleUpdate(bot, update)
+		})
+		server := httptest.NewServer(webhook.NewHandler(*secret, workers.Submit))
+		defer server.Close()
+		target = server.URL
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Ошибка чтения %s: %v", path, err)
+		}
+		status, err := post(target, *secret, body)
+		if err != nil {
+			log.Fatalf("Ошибка отправки %s: %v", path, err)
+		}
+		fmt.Printf("%s: %d %s\n", path, status, http.StatusText(status))
+		if status != http.StatusOK {
+			failed = true
+		}
+	}
+
+	if workers != nil {
+		workers.Wait()
+		for _, sent := range bot.Sent() {
+			fmt.Printf("-> чат %d:\n%s\n", sent.ChatID, sent.Text)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// post отправляет одно обновление на вебхук и возвращает код ответа.
+func post(url, secret string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.SecretHeader, secret)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
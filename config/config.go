@@ -0,0 +1,221 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config описывает настройки бота. Значения берутся из YAML-файла (если он указан),
+// а затем переопределяются переменными окружения.
+type Config struct {
+	Token   string `yaml:"token"`    // BOT_TOKEN
+	Debug   bool   `yaml:"debug"`    // BOT_DEBUG
+	OwnerID int64  `yaml:"owner_id"` // BOT_OWNER_ID
+	Workers int    `yaml:"workers"`  // BOT_WORKERS, число одновременно обрабатываемых обновлений
+	Mode    string `yaml:"mode"`     // BOT_MODE: polling или webhook
+	// Genders — варианты пола, из которых выбирают при регистрации (BOT_GENDERS, через запятую).
+	Genders []string `yaml:"genders"`
+	// ShutdownTimeout — сколько ждать завершения обработчиков при остановке (BOT_SHUTDOWN_TIMEOUT).
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	Webhook         Webhook       `yaml:"webhook"`
+	Mongo           Mongo         `yaml:"mongo"`
+}
+
+// Режимы получения обновлений.
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// Webhook описывает HTTP-сервер для приёма обновлений в режиме webhook.
+type Webhook struct {
+	URL      string `yaml:"url"`       // WEBHOOK_URL — публичный адрес, который сообщается Telegram
+	Listen   string `yaml:"listen"`    // WEBHOOK_LISTEN — адрес, на котором слушает сервер
+	Path     string `yaml:"path"`      // WEBHOOK_PATH — путь, на который Telegram присылает обновления
+	Secret   string `yaml:"secret"`    // WEBHOOK_SECRET — значение заголовка X-Telegram-Bot-Api-Secret-Token
+	CertFile string `yaml:"cert_file"` // WEBHOOK_CERT_FILE — сертификат, если TLS не завершается на прокси
+	KeyFile  string `yaml:"key_file"`  // WEBHOOK_KEY_FILE
+}
+
+// defaultWorkers — число обработчиков обновлений по умолчанию.
+const defaultWorkers = 8
+
+// Mongo описывает подключение к MongoDB.
+type Mongo struct {
+	URI      string `yaml:"uri"`      // MONGO_URI
+	Database string `yaml:"database"` // MONGO_DATABASE
+}
+
+// Load читает конфигурацию из файла path (может быть пустым) и переменных окружения,
+// после чего проверяет, что все обязательные значения заданы.
+func Load(path string) (*Config, error) {
+	cfg := &Config{
+		Workers:         defaultWorkers,
+		Mode:            ModePolling,
+		ShutdownTimeout: 30 * time.Second,
+		Webhook:         Webhook{Listen: ":8443", Path: "/telegram"},
+		Genders:         []string{"мужской", "женский"},
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile заполняет конфигурацию значениями из YAML-файла.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("чтение файла конфигурации %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("разбор файла конфигурации %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv переопределяет значения конфигурации переменными окружения.
+func (c *Config) loadEnv() error {
+	if v, ok := os.LookupEnv("BOT_TOKEN"); ok {
+		c.Token = v
+	}
+	if v, ok := os.LookupEnv("BOT_DEBUG"); ok {
+		debug, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("BOT_DEBUG: ожидается true или false, получено %q", v)
+		}
+		c.Debug = debug
+	}
+	if v, ok := os.LookupEnv("BOT_OWNER_ID"); ok {
+		id, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("BOT_OWNER_ID: ожидается числовой Telegram ID, получено %q", v)
+		}
+		c.OwnerID = id
+	}
+	if v, ok := os.LookupEnv("BOT_WORKERS"); ok {
+		workers, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("BOT_WORKERS: ожидается целое число, получено %q", v)
+		}
+		c.Workers = workers
+	}
+	if v, ok := os.LookupEnv("BOT_SHUTDOWN_TIMEOUT"); ok {
+		timeout, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("BOT_SHUTDOWN_TIMEOUT: ожидается длительность вида 30s, получено %q", v)
+		}
+		c.ShutdownTimeout = timeout
+	}
+	if v, ok := os.LookupEnv("BOT_MODE"); ok {
+		c.Mode = v
+	}
+	if v, ok := os.LookupEnv("BOT_GENDERS"); ok {
+		c.Genders = nil
+		for _, gender := range strings.Split(v, ",") {
+			if gender = strings.TrimSpace(gender); gender != "" {
+				c.Genders = append(c.Genders, gender)
+			}
+		}
+	}
+	envStrings := map[string]*string{
+		"WEBHOOK_URL":       &c.Webhook.URL,
+		"WEBHOOK_LISTEN":    &c.Webhook.Listen,
+		"WEBHOOK_PATH":      &c.Webhook.Path,
+		"WEBHOOK_SECRET":    &c.Webhook.Secret,
+		"WEBHOOK_CERT_FILE": &c.Webhook.CertFile,
+		"WEBHOOK_KEY_FILE":  &c.Webhook.KeyFile,
+	}
+	for name, field := range envStrings {
+		if v, ok := os.LookupEnv(name); ok {
+			*field = v
+		}
+	}
+	if v, ok := os.LookupEnv("MONGO_URI"); ok {
+		c.Mongo.URI = v
+	}
+	if v, ok := os.LookupEnv("MONGO_DATABASE"); ok {
+		c.Mongo.Database = v
+	}
+	return nil
+}
+
+// Validate проверяет, что обязательные параметры заданы, и возвращает
+// ошибку с перечислением всех отсутствующих значений.
+func (c *Config) Validate() error {
+	var missing []string
+	if strings.TrimSpace(c.Token) == "" {
+		missing = append(missing, "token (BOT_TOKEN)")
+	}
+	if c.OwnerID == 0 {
+		missing = append(missing, "owner_id (BOT_OWNER_ID)")
+	}
+	if strings.TrimSpace(c.Mongo.URI) == "" {
+		missing = append(missing, "mongo.uri (MONGO_URI)")
+	}
+	if strings.TrimSpace(c.Mongo.Database) == "" {
+		missing = append(missing, "mongo.database (MONGO_DATABASE)")
+	}
+	switch c.Mode {
+	case ModePolling:
+	case ModeWebhook:
+		if strings.TrimSpace(c.Webhook.URL) == "" {
+			missing = append(missing, "webhook.url (WEBHOOK_URL)")
+		}
+		if strings.TrimSpace(c.Webhook.Listen) == "" {
+			missing = append(missing, "webhook.listen (WEBHOOK_LISTEN)")
+		}
+		if strings.TrimSpace(c.Webhook.Secret) == "" {
+			missing = append(missing, "webhook.secret (WEBHOOK_SECRET)")
+		} else if !validSecret(c.Webhook.Secret) {
+			return errors.New("webhook.secret (WEBHOOK_SECRET) должен содержать от 1 до 256 символов A-Z, a-z, 0-9, _ и -")
+		}
+		if (c.Webhook.CertFile == "") != (c.Webhook.KeyFile == "") {
+			return errors.New("webhook.cert_file и webhook.key_file задаются только вместе")
+		}
+	default:
+		return fmt.Errorf("mode (BOT_MODE): ожидается %q или %q, получено %q", ModePolling, ModeWebhook, c.Mode)
+	}
+	if c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("shutdown_timeout (BOT_SHUTDOWN_TIMEOUT) должен быть положительным, получено %s", c.ShutdownTimeout)
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("workers (BOT_WORKERS) должно быть не меньше 1, получено %d", c.Workers)
+	}
+	if len(c.Genders) == 0 {
+		missing = append(missing, "genders (BOT_GENDERS)")
+	}
+	if len(missing) > 0 {
+		return errors.New("не заданы обязательные параметры конфигурации: " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// validSecret проверяет секретный токен вебхука по правилам Telegram.
+func validSecret(secret string) bool {
+	if len(secret) == 0 || len(secret) > 256 {
+		return false
+	}
+	for _, r := range secret {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
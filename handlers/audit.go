@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// auditResultLimit — сколько символов ответа бота сохраняется в записи журнала.
+	auditResultLimit = 300
+	// auditQueryLimit — сколько записей выводит команда "аудит".
+	auditQueryLimit = 30
+)
+
+// auditRecorder пропускает сообщения к Telegram и запоминает первый текстовый ответ,
+// который затем попадает в журнал как результат действия.
+type auditRecorder struct {
+	Messenger
+	mu     sync.Mutex
+	result string
+}
+
+func newAuditRecorder(bot Messenger) *auditRecorder {
+	return &auditRecorder{Messenger: bot}
+}
+
+func (r *auditRecorder) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var text string
+	switch msg := c.(type) {
+	case tgbotapi.MessageConfig:
+		text = msg.Text
+	case tgbotapi.PhotoConfig:
+		text = msg.Caption
+	case tgbotapi.EditMessageTextConfig:
+		text = msg.Text
+	case tgbotapi.DocumentConfig:
+		text = msg.Caption
+	}
+	r.mu.Lock()
+	if r.result == "" {
+		r.result = text
+	}
+	r.mu.Unlock()
+	return r.Messenger.Send(c)
+}
+
+// recordAudit сохраняет в журнал действие пользователя и ответ бота на него.
+func recordAudit(actor *tgbotapi.User, action, args string, recorder *auditRecorder) {
+	recorder.mu.Lock()
+	result := recorder.result
+	recorder.mu.Unlock()
+	if runes := []rune(result); len(runes) > auditResultLimit {
+		result = string(runes[:auditResultLimit]) + "…"
+	}
+	entry := models.AuditEntry{
+		Date:          time.Now(),
+		ActorID:       actor.ID,
+		ActorUsername: strings.ToLower(actor.UserName),
+		Action:        action,
+		Target:        auditTarget(args),
+		Args:          args,
+		Result:        result,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := store.AddAudit(ctx, entry); err != nil {
+		log.Printf("Ошибка записи в журнал действий: %v", err)
+	}
+}
+
+// auditTarget извлекает из аргументов цель действия: первое упоминание @username
+// или идентификатор (анкеты, ивента, заявки).
+func auditTarget(args string) string {
+	fields := strings.FieldsFunc(args, func(r rune) bool {
+		return r == ' ' || r == ',' || r == ':' || r == '\n'
+	})
+	for _, f := range fields {
+		if strings.HasPrefix(f, "@") && len(f) > 1 {
+			return strings.ToLower(f)
+		}
+		if primitive.IsValidObjectID(f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// parseAuditDate разбирает дату в формате ДД.ММ.ГГГГ.
+func parseAuditDate(s string) (time.Time, error) {
+	return time.ParseInLocation("02.01.2006", s, time.Local)
+}
+
+// handleAudit обрабатывает команду "аудит [от @username] [над @username|ID] [с ДД.ММ.ГГГГ] [по ДД.ММ.ГГГГ]".
+func handleAudit(bot Messenger, message *tgbotapi.Message, args string) {
+	usage := "Используйте: аудит [от @username] [над @username или ID] [с ДД.ММ.ГГГГ] [по ДД.ММ.ГГГГ]"
+	filter := storage.AuditFilter{Limit: auditQueryLimit}
+	fields := strings.Fields(args)
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 >= len(fields) {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат команды.\n"+usage))
+			return
+		}
+		value := fields[i+1]
+		switch strings.ToLower(fields[i]) {
+		case "от":
+			filter.ActorUsername = strings.ToLower(strings.TrimPrefix(value, "@"))
+		case "над":
+			filter.Target = auditTarget(value)
+			if filter.Target == "" {
+				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Цель указывается как @username или ID.\n"+usage))
+				return
+			}
+		case "с":
+			since, err := parseAuditDate(value)
+			if err != nil {
+				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверная дата: "+value+"\n"+usage))
+				return
+			}
+			filter.Since = since
+		case "по":
+			until, err := parseAuditDate(value)
+			if err != nil {
+				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверная дата: "+value+"\n"+usage))
+				return
+			}
+			// Дата "по" включается в период целиком.
+			filter.Until = until.AddDate(0, 0, 1)
+		default:
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неизвестный фильтр: "+fields[i]+"\n"+usage))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	entries, err := store.FindAudit(ctx, filter)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении журнала действий."))
+		return
+	}
+	if len(entries) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Нет записей за выбранный период."))
+		return
+	}
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Журнал действий (последние %d):\n", len(entries)))
+	for _, entry := range entries {
+		line := fmt.Sprintf("%s, @%s: %s", entry.Date.Format("02.01.2006 15:04"), entry.ActorUsername, entry.Action)
+		if entry.Args != "" {
+			line += " " + entry.Args
+		}
+		if outcome := firstLine(entry.Result); outcome != "" {
+			line += " → " + outcome
+		}
+		result.WriteString(line + "\n")
+	}
+	sendLong(bot, message.Chat.ID, result.String())
+}
+
+// firstLine возвращает первую строку текста.
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
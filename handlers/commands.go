@@ -2,187 +2,153 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
 	"time"
 
-	// Импорт необходимых пакетов MongoDB.
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"telegram-bot-go/config"
 	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-// Глобальные переменные для доступа к базе и коллекциям.
+// Глобальные переменные для доступа к хранилищу и конфигурации.
 var (
-	DB             *mongo.Database
-	userCollection *mongo.Collection
-	logsCollection *mongo.Collection
+	store     storage.Storage
+	botConfig *config.Config
 )
 
-// InitHandlers объединяет функциональность: сохраняет указатель на базу данных,
-// инициализирует коллекции (users и logs), создает TTL-индекс для логов и выводит сообщение об инициализации.
-func InitHandlers(database *mongo.Database) {
-	// Сохраняем базу данных в глобальной переменной.
-	DB = database
-
-	// Инициализируем коллекции.
-	userCollection = database.Collection("users")
-	logsCollection = database.Collection("logs")
-
-	// Создаем TTL-индекс для логов (удаление документов старше 30 дней = 2592000 секунд).
-	indexModel := mongo.IndexModel{
-		Keys:    bson.M{"date": 1},
-		Options: options.Index().SetExpireAfterSeconds(2592000),
-	}
-	_, err := logsCollection.Indexes().CreateOne(context.Background(), indexModel)
-	if err != nil {
-		log.Printf("Ошибка создания TTL индекса для логов: %v", err)
-	}
-
-	fmt.Println("Handlers инициализированы: база данных установлена, коллекции users и logs инициализированы.")
+// InitHandlers сохраняет хранилище и конфигурацию для использования в обработчиках.
+func InitHandlers(s storage.Storage, cfg *config.Config) {
+	store = s
+	botConfig = cfg
+	log.Println("Handlers инициализированы: хранилище и конфигурация установлены.")
 }
 
-// AddLogEvent записывает событие изменения ресурса (при добавлении или передаче) в коллекцию логов.
+// AddLogEvent записывает событие изменения ресурса (при добавлении или передаче) в журнал.
 func AddLogEvent(userProfile models.UserProfile, changeAmount int, resource string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	event := bson.M{
-		"date":          time.Now(),
-		"telegram_id":   userProfile.TelegramID,
-		"username":      userProfile.Username,
-		"name":          userProfile.Name,
-		"change_amount": changeAmount,
-		"resource":      resource,
-	}
-	_, err := logsCollection.InsertOne(ctx, event)
-	return err
+	return store.AddLog(ctx, models.LogEntry{
+		Date:         time.Now(),
+		TelegramID:   userProfile.TelegramID,
+		Username:     userProfile.Username,
+		Name:         userProfile.Name,
+		ChangeAmount: changeAmount,
+		Resource:     resource,
+	})
 }
 
-// HandleCommand обрабатывает стандартные команды для обычных пользователей.
-func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	cmd := strings.ToLower(strings.TrimSpace(message.Text))
-	switch cmd {
-	case "где ром":
-		resetRegistrationSessions()
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Все выпили, Капитан!"))
-	case "регистрация":
-		StartRegistration(bot, message)
-	case "анкета":
-		showUserProfile(bot, message)
-	case "статистика":
-		handleStatistic(bot, message)
-	case "удалить анкету":
-		handleDeleteProfile(bot, message)
-	// команды помощи:
-	case "помоги", "помощь", "я забыл", "забыл", "список команд", "что ты умеешь", "что ты делаешь":
-		handleHelp(bot, message)
-	default:
-		parts := strings.Fields(cmd)
-		if len(parts) >= 2 {
-			switch parts[0] {
-			case "изменить":
-				if len(parts) < 3 {
-					bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: изменить имя НовоеИмя"))
-					return
-				}
-				field := parts[1]
-				newValue := strings.Join(parts[2:], " ")
-				changeUserProfileField(bot, message, field, newValue)
-			case "добавить":
-				if len(parts) < 3 {
-					bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: добавить обломки 5"))
-					return
-				}
-				handleAdd(bot, message, parts[1], parts[2])
-			case "потерять":
-				if len(parts) < 2 {
-					bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: потерять обломки 5"))
-					return
-				}
-				handleShow(bot, message, parts[1], parts[2])
-			case "передать":
-				// Формат: передать (обломки или пиастры) (@username) (количество)
-				if len(parts) < 4 {
-					bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Пример: передать обломки @username 5"))
-					return
-				}
-				handleTransfer(bot, message, parts[1], parts[2], parts[3])
-			}
-		}
+// handleChangeCommand разбирает команду "изменить [поле] [значение]".
+func handleChangeCommand(bot Messenger, message *tgbotapi.Message, args string) {
+	parts := strings.Fields(args)
+	if len(parts) < 2 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: изменить имя НовоеИмя"))
+		return
 	}
+	// Названия дополнительных полей могут состоять из нескольких слов, поэтому они проверяются первыми.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if field, value, ok := matchCustomField(ctx, args); ok {
+		changeCustomField(bot, message, field, value)
+		return
+	}
+	changeUserProfileField(bot, message, strings.ToLower(parts[0]), strings.Join(parts[1:], " "))
 }
 
-// HandleNonCommandMessage обрабатывает некомандные сообщения (например, шаги регистрации).
-func HandleNonCommandMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	if _, ok := registrationSessions[message.From.ID]; ok {
-		ProcessRegistrationStep(bot, message)
-	}
+// HandleNonCommandMessage обрабатывает некомандные сообщения (например, ответы в диалоге регистрации).
+func HandleNonCommandMessage(bot Messenger, message *tgbotapi.Message) {
+	ProcessConversation(bot, message)
 }
 
 // SaveUserProfile сохраняет или обновляет анкету пользователя в базе.
 func SaveUserProfile(profile models.UserProfile) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	filter := bson.M{"telegram_id": profile.TelegramID}
-	var existing models.UserProfile
-	err := userCollection.FindOne(ctx, filter).Decode(&existing)
-	if err == nil {
-		update := bson.M{"$set": profile}
-		userCollection.UpdateOne(ctx, filter, update)
-	} else {
-		userCollection.InsertOne(ctx, profile)
+	if err := store.SaveProfile(ctx, profile); err != nil {
+		log.Printf("Ошибка сохранения анкеты %d: %v", profile.TelegramID, err)
 	}
 }
 
 // showUserProfile извлекает анкету пользователя из базы и отправляет её.
-func showUserProfile(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+func showUserProfile(bot Messenger, message *tgbotapi.Message) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	filter := bson.M{"telegram_id": message.From.ID}
-	var profile models.UserProfile
-	err := userCollection.FindOne(ctx, filter).Decode(&profile)
+	profile, err := store.GetProfile(ctx, message.From.ID)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Анкета не найдена. Зарегистрируйтесь командой: регистрация"))
 		return
 	}
-	caption := fmt.Sprintf(
+	sendProfileCard(bot, message.Chat.ID, profile, profileCard(profile, itemNames(ctx), listCustomFields(ctx)))
+}
+
+// profileCard формирует текст анкеты вместе с заполненными дополнительными полями.
+func profileCard(profile *models.UserProfile, names map[primitive.ObjectID]string, fields []models.ProfileField) string {
+	return fmt.Sprintf(
 		"Имя: %s\nРаса: %s\nВозраст: %s\nРост и вес: %s\nПол: %s\nРанг: %s\nКоманда: %s\nОбломки: %d\nПиастры: %d\nИнвентарь: %s",
-		profile.Name, profile.Race, profile.Age, profile.HeightWeight,
+		profile.Name, profile.Race, profile.AgeText(), profile.HeightWeightText(),
 		profile.Gender, profile.Rank, profile.Team, profile.Oblomki,
-		profile.Piastry, profile.Inventory)
-	photoMsg := tgbotapi.NewPhoto(message.Chat.ID, tgbotapi.FileID(profile.PhotoFileID))
-	photoMsg.Caption = caption
+		profile.Piastry, inventorySummary(profile, names)) + customFieldsText(profile, fields)
+}
+
+// sendProfileCard отправляет анкету: фотографию с текстом в подписи или, если текст не помещается
+// в подпись, фотографию и текст отдельными сообщениями.
+func sendProfileCard(bot Messenger, chatID int64, profile *models.UserProfile, card string) {
+	if profile.PhotoFileID == "" {
+		sendLong(bot, chatID, card)
+		return
+	}
+	photoMsg := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(profile.PhotoFileID))
+	if textLen(card) <= captionLimit {
+		photoMsg.Caption = card
+		bot.Send(photoMsg)
+		return
+	}
 	bot.Send(photoMsg)
+	sendLong(bot, chatID, card)
+}
+
+// profileFieldNames — стандартные поля анкеты, которые меняет команда "изменить", и их ключи
+// (см. profileFieldUpdate).
+var profileFieldNames = map[string]string{
+	"имя":      "name",
+	"раса":     "race",
+	"возраст":  "age",
+	"ростивес": "height_weight",
+	"пол":      "gender",
+	"ранг":     "rank",
+	"команда":  "team",
 }
 
-// changeUserProfileField изменяет указанное поле анкеты.
-func changeUserProfileField(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, newValue string) {
-	allowedFields := map[string]string{
-		"имя":       "name",
-		"раса":      "race",
-		"возраст":   "age",
-		"ростивес":  "height_weight",
-		"пол":       "gender",
-		"ранг":      "rank",
-		"команда":   "team",
-		"инвентарь": "inventory",
-	}
-	dbField, ok := allowedFields[field]
+// changeUserProfileField изменяет указанное стандартное поле анкеты.
+func changeUserProfileField(bot Messenger, message *tgbotapi.Message, field, newValue string) {
+	if field == "инвентарь" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			"Инвентарь теперь состоит из предметов каталога: их выдаёт администрация, а вы можете использовать или выбросить их. Посмотреть: инвентарь"))
+		return
+	}
+	dbField, ok := profileFieldNames[field]
 	if !ok {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Поле для изменения не поддерживается."))
 		return
 	}
+	// Поля из анкеты регистрации проверяются так же, как при регистрации.
+	if validate := registrationValidator(dbField); validate != nil {
+		var err error
+		if newValue, err = validate(strings.TrimSpace(newValue)); err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
+			return
+		}
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	filter := bson.M{"telegram_id": message.From.ID}
-	update := bson.M{"$set": bson.M{dbField: newValue}}
-	_, err := userCollection.UpdateOne(ctx, filter, update)
+	err := store.SetProfileFields(ctx, message.From.ID, profileFieldUpdate(dbField, newValue))
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении профиля."))
 		return
@@ -191,45 +157,79 @@ func changeUserProfileField(bot *tgbotapi.BotAPI, message *tgbotapi.Message, fie
 	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
 }
 
+// changeCustomField изменяет дополнительное поле анкеты, если игрок может заполнять его сам.
+func changeCustomField(bot Messenger, message *tgbotapi.Message, field *models.ProfileField, value string) {
+	if !field.Editable {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Поле «%s» заполняет администрация.", field.Name)))
+		return
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Укажите значение. Например: изменить %s (значение)", field.Key)))
+		return
+	}
+	value, err := validateFieldValue(*field, value)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := store.SetProfileFields(ctx, message.From.ID, map[string]interface{}{"fields." + field.ID.Hex(): value}); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении профиля."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Поле '%s' успешно изменено.", field.Name)))
+}
+
 // handleAdd обрабатывает команды вида "добавить обломки 5" или "добавить пиастры 5".
-func handleAdd(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, valueStr string) {
+// Баланс не меняется сразу: создаётся заявка, которую должна одобрить администрация.
+func handleAdd(bot Messenger, message *tgbotapi.Message, field, valueStr string) {
 	num, err := strconv.Atoi(strings.TrimSpace(valueStr))
-	if err != nil {
+	if err != nil || num <= 0 {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверное значение количества."))
 		return
 	}
 	var dbField string
 	if strings.ToLower(field) == "обломки" {
-		dbField = "oblomki"
+		dbField = models.ResourceOblomki
 	} else if strings.ToLower(field) == "пиастры" {
-		dbField = "piastry"
+		dbField = models.ResourcePiastry
 	} else {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверное поле. Используйте 'обломки' или 'пиастры'."))
 		return
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	filter := bson.M{"telegram_id": message.From.ID}
-	update := bson.M{"$inc": bson.M{dbField: num}}
-	_, err = userCollection.UpdateOne(ctx, filter, update)
+	profile, err := store.GetProfile(ctx, message.From.ID)
 	if err != nil {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при обновлении профиля."))
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Анкета не найдена. Зарегистрируйтесь командой: регистрация"))
 		return
 	}
-	reply := fmt.Sprintf("Добавлено %d к %s.", num, field)
-	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
-	// Запись лога
-	var currentUser models.UserProfile
-	if err := userCollection.FindOne(ctx, filter).Decode(&currentUser); err == nil {
-		AddLogEvent(currentUser, num, field)
+	request := &models.GrantRequest{
+		CreatedAt:     time.Now(),
+		TelegramID:    profile.TelegramID,
+		Username:      profile.Username,
+		Name:          profile.Name,
+		Resource:      dbField,
+		ResourceLabel: strings.ToLower(field),
+		Amount:        num,
+		Status:        models.GrantPending,
+	}
+	if err := store.CreateGrantRequest(ctx, request); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при создании заявки."))
+		return
 	}
+	notifyAdminsAboutGrant(bot, request)
+	reply := fmt.Sprintf("Заявка на %d %s отправлена администрации. Посмотреть свои заявки: мои заявки", num, request.ResourceLabel)
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
 }
 
 // handleShow выводит текущее значение ресурса.
-func handleShow(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, valueStr string) {
+func handleShow(bot Messenger, message *tgbotapi.Message, field, valueStr string) {
 	// Преобразуем строку в число
 	num, err := strconv.Atoi(strings.TrimSpace(valueStr))
-	if err != nil {
+	if err != nil || num <= 0 {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверное значение количества."))
 		return
 	}
@@ -238,9 +238,9 @@ func handleShow(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, valueStr
 	var dbField string
 	switch strings.ToLower(field) {
 	case "обломки":
-		dbField = "oblomki"
+		dbField = models.ResourceOblomki
 	case "пиастры":
-		dbField = "piastry"
+		dbField = models.ResourcePiastry
 	default:
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверное поле. Используйте 'обломки' или 'пиастры'."))
 		return
@@ -250,10 +250,13 @@ func handleShow(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, valueStr
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Поиск пользователя по telegram_id и операция вычитания (увеличиваем значение на -num)
-	filter := bson.M{"telegram_id": message.From.ID}
-	update := bson.M{"$inc": bson.M{dbField: -num}}
-	_, err = userCollection.UpdateOne(ctx, filter, update)
+	// Списание только при достаточном балансе, чтобы он не уходил в минус.
+	currentUser, err := store.DebitBalance(ctx, message.From.ID, dbField, num)
+	if errors.Is(err, storage.ErrInsufficientFunds) {
+		reply := fmt.Sprintf("У вас недостаточно %s.", field)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
+		return
+	}
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при обновлении профиля."))
 		return
@@ -264,15 +267,12 @@ func handleShow(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, valueStr
 	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
 
 	// Запись лога операции (записываем отрицательное значение)
-	var currentUser models.UserProfile
-	if err := userCollection.FindOne(ctx, filter).Decode(&currentUser); err == nil {
-		AddLogEvent(currentUser, -num, field)
-	}
+	AddLogEvent(*currentUser, -num, field)
 }
 
 // handleTransfer осуществляет передачу ресурса от отправителя к получателю.
 // Формат команды: передать (обломки или пиастры) (@username) (количество)
-func handleTransfer(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, targetUser, amountStr string) {
+func handleTransfer(bot Messenger, message *tgbotapi.Message, field, targetUser, amountStr string) {
 	amount, err := strconv.Atoi(amountStr)
 	if err != nil || amount <= 0 {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверное значение количества для передачи."))
@@ -281,9 +281,9 @@ func handleTransfer(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, targ
 	var dbField string
 	switch strings.ToLower(field) {
 	case "обломки":
-		dbField = "oblomki"
+		dbField = models.ResourceOblomki
 	case "пиастры":
-		dbField = "piastry"
+		dbField = models.ResourcePiastry
 	default:
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверное поле. Используйте 'обломки' или 'пиастры'."))
 		return
@@ -292,55 +292,42 @@ func handleTransfer(bot *tgbotapi.BotAPI, message *tgbotapi.Message, field, targ
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	// Получаем профиль отправителя.
-	donorFilter := bson.M{"telegram_id": message.From.ID}
-	var donor models.UserProfile
-	err = userCollection.FindOne(ctx, donorFilter).Decode(&donor)
+	donor, err := store.GetProfile(ctx, message.From.ID)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ваша анкета не найдена."))
 		return
 	}
-	var donorAmount int
-	if dbField == "oblomki" {
-		donorAmount = donor.Oblomki
-	} else {
-		donorAmount = donor.Piastry
-	}
-	if donorAmount < amount {
-		reply := fmt.Sprintf("У вас недостаточно %s для передачи.", field)
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
-		return
-	}
 	// Получаем профиль получателя (username хранится в нижнем регистре).
-	recipientFilter := bson.M{"username": strings.ToLower(targetUsername)}
-	var recipient models.UserProfile
-	err = userCollection.FindOne(ctx, recipientFilter).Decode(&recipient)
+	recipient, err := store.GetProfileByUsername(ctx, strings.ToLower(targetUsername))
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Профиль получателя не найден. Убедитесь, что пользователь зарегистрирован."))
 		return
 	}
-	// Обновляем профиль отправителя: списываем ресурс.
-	donorUpdate := bson.M{"$inc": bson.M{dbField: -amount}}
-	_, err = userCollection.UpdateOne(ctx, donorFilter, donorUpdate)
-	if err != nil {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при списании средств с вашего баланса."))
+	if recipient.TelegramID == donor.TelegramID {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Нельзя передать ресурс самому себе."))
+		return
+	}
+	// Списание и зачисление выполняются одной транзакцией; баланс проверяется при списании.
+	donor, recipient, err = store.Transfer(ctx, message.From.ID, recipient.TelegramID, dbField, amount)
+	if errors.Is(err, storage.ErrInsufficientFunds) {
+		reply := fmt.Sprintf("У вас недостаточно %s для передачи.", field)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
 		return
 	}
-	// Обновляем профиль получателя: прибавляем ресурс.
-	recipientUpdate := bson.M{"$inc": bson.M{dbField: amount}}
-	_, err = userCollection.UpdateOne(ctx, recipientFilter, recipientUpdate)
 	if err != nil {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при зачислении средств получателю."))
+		log.Printf("Ошибка передачи %s от %d к @%s: %v", dbField, message.From.ID, targetUsername, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при передаче средств. Баланс не изменён."))
 		return
 	}
 	reply := fmt.Sprintf("Передача выполнена успешно. Вы передали %d %s пользователю @%s.", amount, field, targetUsername)
 	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
 	// Записываем логи для отправителя и получателя.
-	AddLogEvent(donor, -amount, field)
-	AddLogEvent(recipient, amount, field)
+	AddLogEvent(*donor, -amount, field)
+	AddLogEvent(*recipient, amount, field)
 }
 
 // handleStatistic открывает инлайн-клавиатуру для выбора варианта статистики.
-func handleStatistic(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+func handleStatistic(bot Messenger, message *tgbotapi.Message) {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Пиастры", "stat:piastry"),
@@ -353,31 +340,53 @@ func handleStatistic(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	bot.Send(msg)
 }
 
-// handleHelp выводит список команд для пользователя.
-func handleHelp(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	helpText := "Команды для обычных пользователей:\n \n" +
-		"• регистрация – начать регистрацию анкеты\n" +
-		"• анкета – показать свою анкету\n" +
-		"• где ром – сбросить незавершённую регистрацию\n" +
-		"• статистика – показать статистику участников\n" +
-		"• изменить [поле] [значение] – изменить указанное поле анкеты\n" +
-		"• добавить [обломки/пиастры] [количество] – пополнить ресурс\n" +
-		"• потерять [обломки/пиастры] – удалить текущее значение ресурса\n" +
-		"• передать [обломки/пиастры] (@username) [количество] – передать ресурс другому участнику\n" +
-		"• удалить анкету – удалить свою анкету (требуется подтверждение)\n\n" +
-		"Команды для администрации:\n" +
-		"• список анкет – вывести краткий список анкет всех участников\n" +
-		"• полный список анкет – вывести каждую анкету с подробностями и фотографией\n" +
-		"• анкета (айди анкеты) – вывести анкету по заданному ID\n" +
-		"• датьадмин @username – назначить пользователя администратором\n" +
-		"• живой – сбросить все активные сеансы регистрации\n" +
-		"• чек лог [день/неделя/месяц] – вывести лог изменений ресурсов\n" +
-		"• начатьивент (имя), (число обломков), (число пиастр) – начать ивент по добавлению валюты\n"
-	bot.Send(tgbotapi.NewMessage(message.Chat.ID, helpText))
+// errUnknownStatistic возвращается для неизвестного варианта статистики.
+var errUnknownStatistic = errors.New("неизвестный вариант статистики")
+
+// loadStatisticPage формирует статистику для постраничного вывода.
+// Вариант статистики: piastry, oblomki или both.
+func loadStatisticPage(ctx context.Context, statType string) (pagedList, error) {
+	var sortOrder storage.ProfileSort
+	var header string
+	switch statType {
+	case "piastry":
+		sortOrder = storage.SortByPiastry
+		header = "Имя | Ранг | Команда | Пиастры\n \n"
+	case "oblomki":
+		sortOrder = storage.SortByOblomki
+		header = "Имя | Ранг | Команда | Обломки\n \n"
+	case "both":
+		sortOrder = storage.SortByName
+		header = "Имя | Ранг | Команда | Обломки | Пиастры\n \n"
+	default:
+		return pagedList{}, errUnknownStatistic
+	}
+
+	profiles, err := store.ListProfiles(ctx, sortOrder)
+	if err != nil {
+		return pagedList{}, err
+	}
+	list := pagedList{Header: header, Empty: header + "Нет данных для отображения."}
+	for _, profile := range profiles {
+		switch statType {
+		case "piastry":
+			list.Lines = append(list.Lines, fmt.Sprintf("%s | %s | %s | %d", profile.Name, profile.Rank, profile.Team, profile.Piastry))
+		case "oblomki":
+			list.Lines = append(list.Lines, fmt.Sprintf("%s | %s | %s | %d", profile.Name, profile.Rank, profile.Team, profile.Oblomki))
+		case "both":
+			list.Lines = append(list.Lines, fmt.Sprintf("%s | %s | %s | %d | %d", profile.Name, profile.Rank, profile.Team, profile.Oblomki, profile.Piastry))
+		}
+	}
+	return list, nil
+}
+
+// handleHelp выводит список команд, сформированный из реестра команд.
+func handleHelp(bot Messenger, message *tgbotapi.Message) {
+	sendLong(bot, message.Chat.ID, helpText())
 }
 
 // handleDeleteProfile отправляет сообщение с инлайн-клавиатурой для подтверждения удаления анкеты.
-func handleDeleteProfile(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+func handleDeleteProfile(bot Messenger, message *tgbotapi.Message) {
 	yesButton := tgbotapi.NewInlineKeyboardButtonData("Да", "deleteprofile:yes")
 	noButton := tgbotapi.NewInlineKeyboardButtonData("Нет", "deleteprofile:no")
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(yesButton, noButton))
@@ -386,20 +395,37 @@ func handleDeleteProfile(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	bot.Send(msg)
 }
 
-// HandleCreateEvent обрабатывает команду создания ивента от администратора.
-// Формат команды:
-//
-//	начатьивент Название ивента, число для обломков, число для пиастр
-
 // HandleCallbackQuery обрабатывает callback-запросы (например, для статистики и подтверждения удаления анкеты).
-func HandleCallbackQuery(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
+func HandleCallbackQuery(bot Messenger, cq *tgbotapi.CallbackQuery) {
+	// Кнопки ивента, магазина и обмена отвечают на callback-запрос сами — всплывающим уведомлением.
+	if strings.HasPrefix(cq.Data, "event:") {
+		HandleEventCallback(bot, cq)
+		return
+	}
+	if strings.HasPrefix(cq.Data, "shop:") {
+		HandleShopCallback(bot, cq)
+		return
+	}
+	if strings.HasPrefix(cq.Data, "trade:") {
+		HandleTradeCallback(bot, cq)
+		return
+	}
+
 	// Отвечаем на callback-запрос, чтобы кнопки перестали мигать.
 	ack := tgbotapi.NewCallback(cq.ID, "")
 	bot.Request(ack)
 
-	// Если запрос относится к ивенту, вызываем отдельный обработчик и завершаем работу.
-	if strings.HasPrefix(cq.Data, "event:") {
-		HandleEventCallback(bot, cq)
+	// Решение администрации по заявке на пополнение; записывается в журнал действий.
+	if strings.HasPrefix(cq.Data, "grant:") {
+		recorder := newAuditRecorder(bot)
+		defer recordAudit(cq.From, "заявка", strings.TrimPrefix(cq.Data, "grant:"), recorder)
+		HandleGrantCallback(recorder, cq)
+		return
+	}
+
+	// Переход между страницами длинного списка.
+	if strings.HasPrefix(cq.Data, "page:") {
+		HandlePageCallback(bot, cq)
 		return
 	}
 
@@ -409,9 +435,7 @@ func HandleCallbackQuery(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
 		case "deleteprofile:yes":
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
-			filter := bson.M{"telegram_id": cq.From.ID}
-			res, err := userCollection.DeleteOne(ctx, filter)
-			if err != nil || res.DeletedCount == 0 {
+			if err := store.DeleteProfile(ctx, cq.From.ID); err != nil {
 				bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Ошибка при удалении анкеты."))
 			} else {
 				bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Анкета удалена."))
@@ -423,53 +447,17 @@ func HandleCallbackQuery(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
 	}
 
 	// Обработка callback-запроса для статистики.
-	var sortOptions *options.FindOptions
-	var header, statType string
-	switch cq.Data {
-	case "stat:piastry":
-		sortOptions = options.Find().SetSort(bson.D{{Key: "piastry", Value: -1}})
-		header = "Имя | Ранг | Команда | Пиастры\n \n"
-		statType = "piastry"
-	case "stat:oblomki":
-		sortOptions = options.Find().SetSort(bson.D{{Key: "oblomki", Value: -1}})
-		header = "Имя | Ранг | Команда | Обломки\n \n"
-		statType = "oblomki"
-	case "stat:both":
-		sortOptions = options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
-		header = "Имя | Ранг | Команда | Обломки | Пиастры\n \n"
-		statType = "both"
-	default:
+	statType := strings.TrimPrefix(cq.Data, "stat:")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := loadStatisticPage(ctx, statType)
+	if errors.Is(err, errUnknownStatistic) {
 		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Неверный выбор статистики."))
 		return
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	cursor, err := userCollection.Find(ctx, bson.M{}, sortOptions)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Ошибка при получении статистики."))
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var result strings.Builder
-	result.WriteString(header)
-	for cursor.Next(ctx) {
-		var profile models.UserProfile
-		if err := cursor.Decode(&profile); err != nil {
-			continue
-		}
-		switch statType {
-		case "piastry":
-			result.WriteString(fmt.Sprintf("%s | %s | %s | %d\n", profile.Name, profile.Rank, profile.Team, profile.Piastry))
-		case "oblomki":
-			result.WriteString(fmt.Sprintf("%s | %s | %s | %d\n", profile.Name, profile.Rank, profile.Team, profile.Oblomki))
-		case "both":
-			result.WriteString(fmt.Sprintf("%s | %s | %s | %d | %d\n", profile.Name, profile.Rank, profile.Team, profile.Oblomki, profile.Piastry))
-		}
-	}
-	if strings.TrimSpace(result.String()) == header {
-		result.WriteString("Нет данных для отображения.")
-	}
-	bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, result.String()))
+	sendPaged(bot, cq.Message.Chat.ID, "stat", statType, list)
 }
@@ -0,0 +1,298 @@
+package handlers_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"telegram-bot-go/config"
+	"telegram-bot-go/handlers"
+	"telegram-bot-go/handlers/handlerstest"
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	ownerID  = 1
+	playerID = 100
+	guestID  = 200 // пользователь без анкеты
+)
+
+// setup подключает обработчики к новому хранилищу в памяти с одной анкетой игрока.
+func setup(t *testing.T) (*storage.MemoryStorage, *handlerstest.RecordingMessenger) {
+	t.Helper()
+	store := storage.NewMemory()
+	handlers.InitHandlers(store, &config.Config{OwnerID: ownerID})
+	err := store.SaveProfile(context.Background(), models.UserProfile{
+		TelegramID: playerID,
+		Username:   "jack",
+		Name:       "Джек",
+		Race:       "Человек",
+		Gender:     "мужской",
+		Rank:       "Ис",
+		Team:       "Наемник",
+		Oblomki:    7,
+		Piastry:    12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, handlerstest.NewRecordingMessenger()
+}
+
+// privateMessage формирует сообщение от пользователя from в личном чате с ботом.
+func privateMessage(from int64, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: from, UserName: "user"},
+		Chat:      &tgbotapi.Chat{ID: from, Type: "private"},
+		Text:      text,
+	}
+}
+
+// groupMessage формирует сообщение от пользователя from в групповом чате.
+func groupMessage(from int64, text string) *tgbotapi.Message {
+	message := privateMessage(from, text)
+	message.Chat = &tgbotapi.Chat{ID: -1001, Type: "supergroup"}
+	return message
+}
+
+// keyboardData возвращает данные кнопок инлайн-клавиатуры по строкам.
+func keyboardData(kb *tgbotapi.InlineKeyboardMarkup) [][]string {
+	if kb == nil {
+		return nil
+	}
+	var rows [][]string
+	for _, row := range kb.InlineKeyboard {
+		var data []string
+		for _, button := range row {
+			if button.CallbackData != nil {
+				data = append(data, *button.CallbackData)
+			}
+		}
+		rows = append(rows, data)
+	}
+	return rows
+}
+
+// checkSent сравнивает отправленные сообщения с ожидаемыми фрагментами текста и клавиатурами.
+func checkSent(t *testing.T, sent []handlerstest.Sent, want []string, wantKeyboard [][]string) {
+	t.Helper()
+	if len(sent) != len(want) {
+		t.Fatalf("отправлено сообщений: %d, ожидалось %d: %q", len(sent), len(want), texts(sent))
+	}
+	for i, w := range want {
+		if !strings.Contains(sent[i].Text, w) {
+			t.Errorf("сообщение %d = %q, ожидался фрагмент %q", i, sent[i].Text, w)
+		}
+	}
+	if len(sent) == 0 {
+		return
+	}
+	got := keyboardData(sent[len(sent)-1].Keyboard)
+	if !equalRows(got, wantKeyboard) {
+		t.Errorf("клавиатура %q, ожидалась %q", got, wantKeyboard)
+	}
+}
+
+// texts возвращает тексты отправленных сообщений для сообщений об ошибках.
+func texts(sent []handlerstest.Sent) []string {
+	var out []string
+	for _, s := range sent {
+		out = append(out, s.Text)
+	}
+	return out
+}
+
+// equalRows сравнивает строки кнопок клавиатуры.
+func equalRows(a, b [][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.Join(a[i], "\x00") != strings.Join(b[i], "\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHandleCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		from         int64
+		text         string
+		want         []string // фрагменты текста каждого отправленного сообщения
+		wantKeyboard [][]string
+		wantAudit    bool
+	}{
+		{
+			name: "анкета",
+			from: playerID,
+			text: "анкета",
+			want: []string{"Имя: Джек\nРаса: Человек"},
+		},
+		{
+			name: "анкета без регистрации",
+			from: guestID,
+			text: "анкета",
+			want: []string{"Анкета не найдена. Зарегистрируйтесь командой: регистрация"},
+		},
+		{
+			name: "помощь",
+			from: playerID,
+			text: "помощь",
+			// Справка длиннее одного сообщения и делится на части.
+			want: []string{"Команды для обычных пользователей:", "• начатьивент"},
+		},
+		{
+			name: "помощь по синониму",
+			from: guestID,
+			text: "что ты умеешь",
+			want: []string{"• регистрация – начать регистрацию анкеты", "• аудит"},
+		},
+		{
+			name: "неизвестная команда",
+			from: playerID,
+			text: "полетели на луну",
+		},
+		{
+			name:         "статистика",
+			from:         playerID,
+			text:         "статистика",
+			want:         []string{"Выберите вариант статистики:"},
+			wantKeyboard: [][]string{{"stat:piastry", "stat:oblomki", "stat:both"}},
+		},
+		{
+			name:         "удалить анкету",
+			from:         playerID,
+			text:         "удалить анкету",
+			want:         []string{"Вы точно хотите удалить анкету?"},
+			wantKeyboard: [][]string{{"deleteprofile:yes", "deleteprofile:no"}},
+		},
+		{
+			name:      "команда администрации от игрока",
+			from:      playerID,
+			text:      "список анкет",
+			want:      []string{"У вас нет прав для выполнения этой команды."},
+			wantAudit: true,
+		},
+		{
+			name:      "команда администрации от владельца",
+			from:      ownerID,
+			text:      "список анкет",
+			want:      []string{"Джек"},
+			wantAudit: true,
+		},
+		{
+			name:      "команда администрации без аргументов",
+			from:      ownerID,
+			text:      "выдать предмет",
+			want:      []string{"Неверный формат. Используйте: выдать предмет"},
+			wantAudit: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, bot := setup(t)
+			handlers.HandleCommand(bot, privateMessage(tt.from, tt.text))
+			checkSent(t, bot.Sent(), tt.want, tt.wantKeyboard)
+
+			audit, err := store.FindAudit(context.Background(), storage.AuditFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(audit) > 0; got != tt.wantAudit {
+				t.Errorf("запись в журнале действий: %v, ожидалось %v", got, tt.wantAudit)
+			}
+		})
+	}
+}
+
+func TestHandleCommandChatType(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+		want    string
+	}{
+		{"регистрация в группе", groupMessage(playerID, "регистрация"), "Команда «регистрация» доступна только в личных сообщениях с ботом"},
+		{"изменение анкеты в группе", groupMessage(playerID, "изменить имя Джек"), "Команда «изменить» доступна только в личных сообщениях с ботом"},
+		{"заявка в группе", groupMessage(playerID, "добавить обломки 5"), "Команда «добавить» доступна только в личных сообщениях с ботом"},
+		{"ивент в личных сообщениях", privateMessage(ownerID, "начатьивент Шторм, 1, 2"), "Команда «начатьивент» доступна только в групповом чате."},
+		{"анкета в группе", groupMessage(playerID, "анкета"), "Имя: Джек"},
+		{"регистрация в личных сообщениях", privateMessage(guestID, "регистрация"), "Введите имя и/или псевдоним:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, bot := setup(t)
+			handlers.HandleCommand(bot, tt.message)
+			checkSent(t, bot.Sent(), []string{tt.want}, nil)
+			// Команда не выполнилась: заявка не создана, ивент не начат.
+			if grants, _ := store.ListGrantRequests(context.Background(), playerID, models.GrantPending); len(grants) > 0 {
+				t.Error("заявка создана из неподходящего чата")
+			}
+			if events, _ := store.ListEvents(context.Background(), models.EventActive); len(events) > 0 {
+				t.Error("ивент начат из неподходящего чата")
+			}
+		})
+	}
+}
+
+func TestHandleUpdateStripsSlashAndMention(t *testing.T) {
+	_, bot := setup(t)
+	handlers.HandleUpdate(bot, tgbotapi.Update{Message: privateMessage(playerID, "/анкета@pirate_bot")})
+	checkSent(t, bot.Sent(), []string{"Имя: Джек"}, nil)
+}
+
+func TestHandleCallbackQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		want         []string
+		wantKeyboard [][]string
+		wantDeleted  bool
+	}{
+		{
+			name: "статистика по обоим ресурсам",
+			data: "stat:both",
+			want: []string{"Джек"},
+		},
+		{
+			name: "неизвестная статистика",
+			data: "stat:rum",
+			want: []string{"Неверный выбор статистики."},
+		},
+		{
+			name: "отмена удаления анкеты",
+			data: "deleteprofile:no",
+			want: []string{"Удаление отменено."},
+		},
+		{
+			name:        "удаление анкеты",
+			data:        "deleteprofile:yes",
+			want:        []string{"Анкета удалена."},
+			wantDeleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, bot := setup(t)
+			handlers.HandleCallbackQuery(bot, &tgbotapi.CallbackQuery{
+				ID:      "cb",
+				From:    &tgbotapi.User{ID: playerID, UserName: "jack"},
+				Message: privateMessage(playerID, "Выберите вариант статистики:"),
+				Data:    tt.data,
+			})
+			checkSent(t, bot.Sent(), tt.want, tt.wantKeyboard)
+			if len(bot.Requests()) != 1 {
+				t.Errorf("ответов на callback: %d, ожидался 1", len(bot.Requests()))
+			}
+			_, err := store.GetProfile(context.Background(), playerID)
+			if deleted := err != nil; deleted != tt.wantDeleted {
+				t.Errorf("анкета удалена: %v, ожидалось %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
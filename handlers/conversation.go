@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// conversationTimeout — сколько по умолчанию ждать ответа на шаг диалога.
+const conversationTimeout = 24 * time.Hour
+
+// Служебные ответы, которыми пользователь управляет диалогом на любом шаге.
+const (
+	conversationBack   = "назад"
+	conversationSkip   = "пропустить"
+	conversationCancel = "отмена"
+)
+
+// inputKind — допустимые виды ответа на шаг диалога (можно сочетать через |).
+type inputKind int
+
+const (
+	inputText  inputKind = 1 << iota
+	inputPhoto           // фотография или изображение, отправленное файлом
+)
+
+// conversationStep описывает один вопрос диалога.
+type conversationStep struct {
+	Key      string        // ключ ответа в Conversation.Values
+	Prompt   string        // вопрос пользователю
+	Input    inputKind     // допустимые виды ответа; 0 — только текст
+	Optional bool          // шаг можно пропустить ответом "пропустить"
+	Timeout  time.Duration // сколько ждать ответа; 0 — conversationTimeout
+	// Validate проверяет и нормализует текстовый ответ. Текст ошибки показывается пользователю.
+	Validate func(value string) (string, error)
+}
+
+// conversationFlow описывает диалог: шаги по порядку и действие после ответа на последний из них.
+type conversationFlow struct {
+	Steps []conversationStep
+	// Build, если задан, формирует шаги вместо Steps при каждом ответе — например, с учётом настроек из базы.
+	Build func(ctx context.Context) []conversationStep
+	// Finish получает все ответы диалога; message — последнее сообщение пользователя.
+	Finish func(bot Messenger, message *tgbotapi.Message, values map[string]string)
+	// Cancelled — ответ на "отмена".
+	Cancelled string
+}
+
+// conversationFlows — диалоги по видам (Conversation.Kind).
+var conversationFlows map[string]*conversationFlow
+
+func init() {
+	conversationFlows = map[string]*conversationFlow{
+		"registration": registrationFlow,
+	}
+}
+
+// steps возвращает шаги диалога.
+func (f *conversationFlow) steps(ctx context.Context) []conversationStep {
+	if f.Build != nil {
+		return f.Build(ctx)
+	}
+	return f.Steps
+}
+
+// timeout возвращает время ожидания ответа на шаг.
+func (s conversationStep) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return conversationTimeout
+}
+
+// accepts сообщает, допустим ли вид ответа kind.
+func (s conversationStep) accepts(kind inputKind) bool {
+	if s.Input == 0 {
+		return kind == inputText
+	}
+	return s.Input&kind != 0
+}
+
+// prompt возвращает вопрос шага с подсказкой о служебных ответах.
+func (s conversationStep) prompt(first bool) string {
+	var hints []string
+	if !first {
+		hints = append(hints, "«"+conversationBack+"» — к предыдущему вопросу")
+	}
+	if s.Optional {
+		hints = append(hints, "«"+conversationSkip+"» — оставить пустым")
+	}
+	hints = append(hints, "«"+conversationCancel+"» — прервать")
+	return s.Prompt + "\n(" + strings.Join(hints, ", ") + ")"
+}
+
+// startConversation начинает диалог kind с автором сообщения, заменяя его незавершённый диалог.
+// values — заранее известные ответы (могут быть nil).
+func startConversation(bot Messenger, message *tgbotapi.Message, kind string, values map[string]string) {
+	flow := conversationFlows[kind]
+	conv := &models.Conversation{
+		TelegramID: message.From.ID,
+		ChatID:     message.Chat.ID,
+		Kind:       kind,
+		Values:     values,
+	}
+	if conv.Values == nil {
+		conv.Values = make(map[string]string)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	steps := flow.steps(ctx)
+	if err := saveConversation(ctx, steps[0], conv); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Не удалось начать диалог. Попробуйте позже."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, steps[0].prompt(true)))
+}
+
+// saveConversation делает step текущим шагом диалога и сохраняет диалог, отсчитывая срок ответа заново.
+func saveConversation(ctx context.Context, step conversationStep, conv *models.Conversation) error {
+	conv.StepKey = step.Key
+	conv.ExpiresAt = time.Now().Add(step.timeout())
+	if err := store.SaveConversation(ctx, *conv); err != nil {
+		log.Printf("Ошибка сохранения диалога %d: %v", conv.TelegramID, err)
+		return err
+	}
+	return nil
+}
+
+// stepIndex возвращает номер шага с ключом key или -1, если такого шага больше нет.
+func stepIndex(steps []conversationStep, key string) int {
+	for i, step := range steps {
+		if step.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// firstUnanswered возвращает номер первого шага без ответа (или последнего шага, если ответы есть на все).
+func firstUnanswered(steps []conversationStep, values map[string]string) int {
+	for i, step := range steps {
+		if _, ok := values[step.Key]; !ok {
+			return i
+		}
+	}
+	return len(steps) - 1
+}
+
+// ProcessConversation передаёт сообщение в незавершённый диалог его автора.
+// Диалоги хранятся в базе, поэтому после перезапуска бота пользователь продолжает с того же шага.
+// Диалог меняет лишь горутина, обрабатывающая сообщения его пользователя, — они приходят по порядку.
+func ProcessConversation(bot Messenger, message *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conv, err := store.GetConversation(ctx, message.From.ID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Ошибка получения диалога %d: %v", message.From.ID, err)
+		}
+		return
+	}
+	// Сообщения из других чатов к диалогу не относятся.
+	if message.Chat.ID != conv.ChatID {
+		return
+	}
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(message.Chat.ID, text)) }
+	flow, ok := conversationFlows[conv.Kind]
+	var steps []conversationStep
+	if ok {
+		steps = flow.steps(ctx)
+	}
+	if len(steps) == 0 {
+		log.Printf("Неизвестный вид диалога %q у %d", conv.Kind, conv.TelegramID)
+		deleteConversation(ctx, conv.TelegramID)
+		reply("Диалог больше не поддерживается и прерван.")
+		return
+	}
+	// Шаги ищутся по ключу: пока пользователь отвечал, администратор мог добавить или удалить поля анкеты.
+	i := stepIndex(steps, conv.StepKey)
+	if i < 0 {
+		i = firstUnanswered(steps, conv.Values)
+		if saveConversation(ctx, steps[i], conv) == nil {
+			reply("Вопрос, на который вы отвечали, больше не задаётся. Продолжим с первого вопроса без ответа.\n" + steps[i].prompt(i == 0))
+		}
+		return
+	}
+	step := steps[i]
+
+	text := strings.TrimSpace(message.Text)
+	switch strings.ToLower(text) {
+	case conversationCancel:
+		deleteConversation(ctx, conv.TelegramID)
+		reply(flow.Cancelled)
+		return
+	case conversationBack:
+		if i > 0 {
+			i--
+		}
+		if saveConversation(ctx, steps[i], conv) == nil {
+			reply(steps[i].prompt(i == 0))
+		}
+		return
+	case conversationSkip:
+		if !step.Optional {
+			reply("Этот вопрос нельзя пропустить.\n" + step.prompt(i == 0))
+			return
+		}
+		delete(conv.Values, step.Key)
+		advanceConversation(ctx, bot, message, flow, steps, i, conv)
+		return
+	}
+
+	var value string
+	hasImage := len(message.Photo) > 0 || isImageDocument(message.Document)
+	switch {
+	case hasImage && step.accepts(inputPhoto):
+		if value, err = messagePhoto(bot, message); err != nil {
+			log.Printf("Ошибка получения фотографии от %d: %v", message.From.ID, err)
+			reply("Не удалось принять изображение (файл должен быть не больше 10 МБ). Попробуйте отправить его как фотографию.\n" + step.prompt(i == 0))
+			return
+		}
+	case !hasImage && text != "" && step.accepts(inputText):
+		value = text
+		if step.Validate != nil {
+			if value, err = step.Validate(text); err != nil {
+				reply(err.Error() + "\n" + step.prompt(i == 0))
+				return
+			}
+		}
+	case step.accepts(inputPhoto) && !step.accepts(inputText):
+		reply("Нужна фотография: отправьте изображение как фото или файлом.\n" + step.prompt(i == 0))
+		return
+	default:
+		reply("Нужен текстовый ответ.\n" + step.prompt(i == 0))
+		return
+	}
+	conv.Values[step.Key] = value
+	advanceConversation(ctx, bot, message, flow, steps, i, conv)
+}
+
+// advanceConversation переходит от шага i к следующему или завершает диалог после последнего.
+func advanceConversation(ctx context.Context, bot Messenger, message *tgbotapi.Message, flow *conversationFlow, steps []conversationStep, i int, conv *models.Conversation) {
+	i++
+	if i == len(steps) {
+		deleteConversation(ctx, conv.TelegramID)
+		flow.Finish(bot, message, conv.Values)
+		return
+	}
+	if err := saveConversation(ctx, steps[i], conv); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Не удалось сохранить ответ. Попробуйте ещё раз."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, steps[i].prompt(false)))
+}
+
+// deleteConversation удаляет диалог пользователя.
+func deleteConversation(ctx context.Context, telegramID int64) {
+	if err := store.DeleteConversation(ctx, telegramID); err != nil {
+		log.Printf("Ошибка удаления диалога %d: %v", telegramID, err)
+	}
+}
+
+// resetConversations прерывает все незавершённые диалоги.
+func resetConversations() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := store.ClearConversations(ctx); err != nil {
+		log.Printf("Ошибка сброса диалогов: %v", err)
+	}
+}
+
+// handleResetConversation прерывает незавершённый диалог вызвавшего (например, регистрацию).
+// Администратор прерывает диалоги всех игроков; такое действие записывается в журнал.
+func handleResetConversation(bot Messenger, message *tgbotapi.Message, _ string) {
+	if isAdmin(message.From.ID) {
+		recorder := newAuditRecorder(bot)
+		defer recordAudit(message.From, "где ром", "", recorder)
+		resetConversations()
+		recorder.Send(tgbotapi.NewMessage(message.Chat.ID, "Все выпили, Капитан!"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	deleteConversation(ctx, message.From.ID)
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Все выпили, Капитан!"))
+}
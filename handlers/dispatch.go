@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleUpdate — единая точка входа для всех обновлений от Telegram.
+func HandleUpdate(bot Messenger, update tgbotapi.Update) {
+	if update.CallbackQuery != nil {
+		HandleCallbackQuery(bot, update.CallbackQuery)
+		return
+	}
+
+	if update.Message == nil {
+		return
+	}
+
+	// Если сообщение начинается со слэша, убираем слэш и упоминание бота.
+	if strings.HasPrefix(update.Message.Text, "/") {
+		update.Message.Text = stripBotMention(strings.TrimPrefix(update.Message.Text, "/"))
+		HandleCommand(bot, update.Message)
+	} else {
+		HandleNonCommandMessage(bot, update.Message)
+	}
+}
+
+// stripBotMention убирает упоминание бота из первого слова команды ("анкета@bot" -> "анкета"),
+// не трогая @username в аргументах.
+func stripBotMention(cmd string) string {
+	end := strings.IndexAny(cmd, " \n")
+	if end == -1 {
+		end = len(cmd)
+	}
+	if i := strings.Index(cmd[:end], "@"); i != -1 {
+		return cmd[:i] + cmd[end:]
+	}
+	return cmd
+}
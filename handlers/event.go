@@ -2,88 +2,238 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
 	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
-// DB – глобальная переменная для доступа к базе данных, инициализируется из main.go.
+const (
+	// eventSchedulerInterval — как часто планировщик проверяет ивенты.
+	eventSchedulerInterval = 30 * time.Second
+	// eventReminderLead — за сколько до завершения ивента напоминать не ответившим игрокам.
+	// Для коротких ивентов напоминание приходит на середине.
+	eventReminderLead = 15 * time.Minute
+)
+
+// announcementMu упорядочивает правки объявлений, чтобы счётчики не откатывались
+// из-за параллельных нажатий разных игроков.
+var announcementMu sync.Mutex
+
+// eventCallbackData формирует данные кнопки ивента: event:<id ивента>:<действие>.
+func eventCallbackData(id primitive.ObjectID, action string) string {
+	return "event:" + id.Hex() + ":" + action
+}
+
+// eventKeyboard возвращает кнопки "Участвую" и "Пропуск" для ивента.
+func eventKeyboard(id primitive.ObjectID) tgbotapi.InlineKeyboardMarkup {
+	participateButton := tgbotapi.NewInlineKeyboardButtonData("Участвую", eventCallbackData(id, models.ChoiceParticipate))
+	skipButton := tgbotapi.NewInlineKeyboardButtonData("Пропуск", eventCallbackData(id, models.ChoiceSkip))
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(participateButton, skipButton))
+}
+
+// countResponses считает участников и пропустивших.
+func countResponses(participants []models.EventParticipant) (joined, skipped int) {
+	for _, p := range participants {
+		if p.Choice == models.ChoiceParticipate {
+			joined++
+		} else {
+			skipped++
+		}
+	}
+	return joined, skipped
+}
 
-// InitHandlers сохраняет указатель на базу данных для использования в обработчиках.
+// eventAnnouncementText формирует текст объявления ивента с текущими счётчиками ответов.
+func eventAnnouncementText(event *models.Event, joined, skipped int) string {
+	var text strings.Builder
+	if event.Status == models.EventClosed {
+		text.WriteString(fmt.Sprintf("Ивент '%s' завершён.\nУчастникам ивента зачислено:\n", event.Name))
+	} else {
+		text.WriteString(fmt.Sprintf("Ивент '%s' запущен!\nУчастникам, принявшим ивент, будет зачислено:\n", event.Name))
+	}
+	text.WriteString(fmt.Sprintf("Обломков: %d\nПиастр: %d\nДата начала: %s",
+		event.Oblomki, event.Piastry, event.StartDate.Format("02.01.2006 15:04")))
+	if !event.EndsAt.IsZero() && event.Status != models.EventClosed {
+		text.WriteString("\nЗавершится: " + event.EndsAt.Format("02.01.2006 15:04"))
+	}
+	text.WriteString(fmt.Sprintf("\n\nУчаствуют: %d\nПропускают: %d", joined, skipped))
+	return text.String()
+}
 
-// EventDetails хранит данные активного ивента.
-type EventDetails struct {
-	Name      string
-	Oblomki   int
-	Piastry   int
-	StartDate time.Time
+// parseEventDuration разбирает длительность ивента: число минут ("90") или запись вида "1h30m".
+func parseEventDuration(s string) (time.Duration, error) {
+	if minutes, err := strconv.Atoi(s); err == nil {
+		if minutes <= 0 {
+			return 0, errors.New("длительность должна быть положительной")
+		}
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New("длительность должна быть положительной")
+	}
+	return d, nil
 }
 
-// currentEvent — глобальная переменная для текущего активного ивента.
-var currentEvent *EventDetails
+// parseEventStart разбирает время начала ивента: "18:30" (сегодня, а если время прошло — завтра),
+// "20.10 18:30" или "20.10.2026 18:30". Время считается в часовом поясе сервера.
+func parseEventStart(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("15:04", s, time.Local); err == nil {
+		start := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.Local)
+		if start.Before(now) {
+			start = start.AddDate(0, 0, 1)
+		}
+		return start, nil
+	}
+	if t, err := time.ParseInLocation("02.01 15:04", s, time.Local); err == nil {
+		return time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.Local), nil
+	}
+	return time.ParseInLocation("02.01.2006 15:04", s, time.Local)
+}
+
+// eventReminderTime возвращает время напоминания по ивенту (нулевое, если у ивента нет длительности).
+func eventReminderTime(event *models.Event) time.Time {
+	if event.EndsAt.IsZero() {
+		return time.Time{}
+	}
+	lead := eventReminderLead
+	if half := event.EndsAt.Sub(event.StartDate) / 2; half < lead {
+		lead = half
+	}
+	return event.EndsAt.Add(-lead)
+}
+
+// createEventUsage — подсказка по формату команды создания ивента.
+const createEventUsage = "Используйте: начатьивент (Имя ивента), (число для обломков), (число для пиастр)[, (длительность)][, (начало)]\n" +
+	"Длительность: 90 (минут) или 1h30m. Начало: 18:30, 20.10 18:30 или 20.10.2026 18:30."
 
 // HandleCreateEvent обрабатывает команду создания ивента.
-// Ожидается формат команды: "начатьивент (Имя ивента), (число для обломков), (число для пиастр)"
-func HandleCreateEvent(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	// Удаляем префикс "начатьивент" и приводим строку к нужному формату.
-	argsStr := strings.TrimSpace(strings.TrimPrefix(message.Text, "начатьивент"))
-	parts := strings.Split(argsStr, ",")
-	if len(parts) < 3 {
-		msg := tgbotapi.NewMessage(message.Chat.ID,
-			"Неверный формат команды.\nИспользуйте: начатьивент (Имя ивента), (число для обломков), (число для пиастр)")
+// Ожидается формат команды: "начатьивент (Имя ивента), (число для обломков), (число для пиастр)[, (длительность)][, (начало)]".
+// Необязательные части различаются по виду: время начала содержит двоеточие.
+func HandleCreateEvent(bot Messenger, message *tgbotapi.Message, args string) {
+	parts := strings.Split(args, ",")
+	if len(parts) < 3 || len(parts) > 5 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Неверный формат команды.\n"+createEventUsage)
 		bot.Send(msg)
 		return
 	}
 
 	eventName := strings.TrimSpace(parts[0])
+	// Отрицательная награда списывала бы валюту у каждого участника.
 	oblomki, err := strconv.Atoi(strings.TrimSpace(parts[1]))
-	if err != nil {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: число для обломков указано неверно."))
+	if err != nil || oblomki < 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: число для обломков указано неверно (нужно целое число не меньше 0)."))
 		return
 	}
 	piastry, err := strconv.Atoi(strings.TrimSpace(parts[2]))
-	if err != nil {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: число для пиастр указано неверно."))
+	if err != nil || piastry < 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: число для пиастр указано неверно (нужно целое число не меньше 0)."))
 		return
 	}
 
-	// Сохраняем данные ивента в глобальной переменной.
-	currentEvent = &EventDetails{
+	now := time.Now()
+	var duration time.Duration
+	start := now
+	for _, part := range parts[3:] {
+		part = strings.TrimSpace(part)
+		if strings.Contains(part, ":") {
+			if start, err = parseEventStart(part, now); err != nil || start.Before(now) {
+				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: время начала указано неверно или уже прошло.\n"+createEventUsage))
+				return
+			}
+			continue
+		}
+		if duration, err = parseEventDuration(part); err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: длительность указана неверно.\n"+createEventUsage))
+			return
+		}
+	}
+
+	// Сохраняем ивент в хранилище; одновременно может идти несколько ивентов.
+	// Ивент с будущим началом ждёт планировщика, который опубликует объявление.
+	event := &models.Event{
 		Name:      eventName,
+		Status:    models.EventActive,
+		CreatedBy: message.From.ID,
 		Oblomki:   oblomki,
 		Piastry:   piastry,
-		StartDate: time.Now(),
+		StartDate: start,
+		ChatID:    message.Chat.ID,
+	}
+	if start.After(now) {
+		event.Status = models.EventScheduled
+	}
+	if duration > 0 {
+		event.EndsAt = start.Add(duration)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := store.CreateEvent(ctx, event); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при сохранении ивента."))
+		return
 	}
 
-	eventMessage := fmt.Sprintf("Ивент '%s' запущен!\nУчастникам, принявшим ивент, будет зачислено:\nОбломков: %d\nПиастр: %d\nДата начала: %s",
-		currentEvent.Name, currentEvent.Oblomki, currentEvent.Piastry, currentEvent.StartDate.Format("02.01.2006 15:04"))
-
-	// Создаём инлайн-клавиатуру с кнопками "Участвую" и "Пропуск".
-	participateButton := tgbotapi.NewInlineKeyboardButtonData("Участвую", "event:participate")
-	skipButton := tgbotapi.NewInlineKeyboardButtonData("Пропуск", "event:skip")
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(participateButton, skipButton),
-	)
+	if event.Status == models.EventScheduled {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Ивент '%s' запланирован на %s (ID: %s).",
+			event.Name, event.StartDate.Format("02.01.2006 15:04"), event.ID.Hex())))
+		return
+	}
+	announceEvent(bot, event)
+}
 
-	msg := tgbotapi.NewMessage(message.Chat.ID, eventMessage)
-	msg.ReplyMarkup = keyboard
-	bot.Send(msg)
+// announceEvent публикует объявление ивента с кнопками в чате ивента и запоминает его,
+// чтобы обновлять в нём счётчики и убрать кнопки после завершения.
+func announceEvent(bot Messenger, event *models.Event) {
+	msg := tgbotapi.NewMessage(event.ChatID, eventAnnouncementText(event, 0, 0))
+	msg.ReplyMarkup = eventKeyboard(event.ID)
+	sent, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Ошибка публикации объявления ивента %s: %v", event.ID.Hex(), err)
+		return
+	}
+	event.Announcement = models.MessageRef{ChatID: sent.Chat.ID, MessageID: sent.MessageID}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := store.SetEventAnnouncement(ctx, event.ID, event.Announcement); err != nil {
+		log.Printf("Ошибка сохранения объявления ивента %s: %v", event.ID.Hex(), err)
+	}
 }
 
-func HandleEventCallback(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
-	// Отправляем ответ на callback-запрос, чтобы кнопка перестала мигать.
-	ack := tgbotapi.NewCallback(cq.ID, "")
-	_, _ = bot.Request(ack)
+// HandleEventCallback обрабатывает кнопки ивента. Формат данных: event:<id ивента>:participate или event:<id ивента>:skip.
+// Результат показывается всплывающим уведомлением, а счётчики — в самом объявлении.
+func HandleEventCallback(bot Messenger, cq *tgbotapi.CallbackQuery) {
+	answer := func(text string) {
+		bot.Request(tgbotapi.NewCallback(cq.ID, text))
+	}
 
-	// Проверяем, что активный ивент установлен.
-	if currentEvent == nil {
-		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Нет активного ивента."))
+	// Кнопки старого формата (без ID ивента) больше не действуют.
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 3 {
+		answer("Этот ивент больше не активен.")
+		return
+	}
+	eventID, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		answer("Неверный выбор.")
+		return
+	}
+	action := parts[2]
+	if action != models.ChoiceParticipate && action != models.ChoiceSkip {
+		answer("Неверный выбор.")
 		return
 	}
 
@@ -91,58 +241,330 @@ func HandleEventCallback(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Формируем фильтр для поиска пользователя по Telegram ID.
-	filter := bson.M{"telegram_id": int64(cq.From.ID)}
-
-	// Объявляем переменную для хранения профиля.
-	var profile models.UserProfile
+	// Проверяем, что ивент существует и ещё идёт. Истёкший ивент закроет наблюдатель.
+	event, err := store.GetEvent(ctx, eventID)
+	if err != nil {
+		answer("Ивент не найден.")
+		return
+	}
+	if event.Status != models.EventActive || event.Expired(time.Now()) {
+		answer("Этот ивент больше не активен.")
+		return
+	}
 
 	// Сначала пробуем получить профиль из базы.
-	err := userCollection.FindOne(ctx, filter).Decode(&profile)
+	profile, err := store.GetProfile(ctx, cq.From.ID)
 	if err != nil {
-		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID,
-			"Анкета не найдена. Зарегистрируйтесь командой: регистрация"))
+		answer("Анкета не найдена. Зарегистрируйтесь командой: регистрация")
 		return
 	}
 
-	if cq.Data == "event:participate" {
-		// Опция «Участвую»: обновляем баланс валют пользователя.
-		newPiastry := profile.Piastry + currentEvent.Piastry
-		newOblomki := profile.Oblomki + currentEvent.Oblomki
+	participant, updated, err := store.RespondToEvent(ctx, event, profile, action)
+	switch {
+	case errors.Is(err, storage.ErrAlreadyResponded):
+		if participant.Choice == models.ChoiceParticipate {
+			answer("Вы уже участвуете в этом ивенте, награда уже начислена.")
+		} else {
+			answer("Вы уже отметили пропуск этого ивента.")
+		}
+		return
+	case errors.Is(err, storage.ErrEventClosed):
+		answer("Этот ивент больше не активен.")
+		return
+	case err != nil:
+		answer("Ошибка обновления профиля.")
+		return
+	}
 
-		update := bson.M{"$set": bson.M{"piastry": newPiastry, "oblomki": newOblomki}}
-		_, err = userCollection.UpdateOne(ctx, filter, update)
-		if err != nil {
-			bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Ошибка обновления профиля."))
+	if updated != nil {
+		answer(fmt.Sprintf("Вы участвуете! Начислено: %d обломков и %d пиастр. Баланс: %d обломков, %d пиастр.",
+			event.Oblomki, event.Piastry, updated.Oblomki, updated.Piastry))
+	} else {
+		answer("Вы пропускаете этот ивент.")
+	}
+	updateEventAnnouncement(bot, event)
+}
+
+// updateEventAnnouncement обновляет счётчики в объявлении ивента, сохраняя кнопки.
+func updateEventAnnouncement(bot Messenger, event *models.Event) {
+	if event.Announcement.MessageID == 0 {
+		return
+	}
+	announcementMu.Lock()
+	defer announcementMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	participants, err := store.ListParticipants(ctx, event.ID)
+	if err != nil {
+		log.Printf("Ошибка получения участников ивента %s: %v", event.ID.Hex(), err)
+		return
+	}
+	joined, skipped := countResponses(participants)
+	ref := event.Announcement
+	bot.Send(tgbotapi.NewEditMessageTextAndMarkup(ref.ChatID, ref.MessageID,
+		eventAnnouncementText(event, joined, skipped), eventKeyboard(event.ID)))
+}
+
+// closeEvent завершает ивент: убирает кнопки из объявления и публикует итоги в его чате.
+// Возвращает текст итогов. Если ивент уже завершён, возвращается storage.ErrEventClosed.
+func closeEvent(bot Messenger, id primitive.ObjectID, closedBy int64) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	event, err := store.CloseEvent(ctx, id, closedBy)
+	if err != nil {
+		return "", err
+	}
+	participants, err := store.ListParticipants(ctx, event.ID)
+	if err != nil {
+		log.Printf("Ошибка получения участников ивента %s: %v", event.ID.Hex(), err)
+	}
+	joined, skipped := countResponses(participants)
+
+	var summary strings.Builder
+	summary.WriteString(fmt.Sprintf("Ивент '%s' завершён.\nУчаствовали: %d\nПропустили: %d\n", event.Name, joined, skipped))
+	summary.WriteString(fmt.Sprintf("Выплачено всего: %d обломков, %d пиастр",
+		joined*event.Oblomki, joined*event.Piastry))
+	if joined > 0 {
+		summary.WriteString("\n\nУчастники:\n")
+		for _, p := range participants {
+			if p.Choice == models.ChoiceParticipate {
+				summary.WriteString(fmt.Sprintf("• %s (@%s)\n", p.Name, p.Username))
+			}
+		}
+	}
+
+	if ref := event.Announcement; ref.MessageID != 0 {
+		announcementMu.Lock()
+		bot.Send(tgbotapi.NewEditMessageText(ref.ChatID, ref.MessageID, eventAnnouncementText(event, joined, skipped)))
+		announcementMu.Unlock()
+		sendLong(bot, ref.ChatID, summary.String())
+	}
+	return summary.String(), nil
+}
+
+// handleCloseEvent обрабатывает команду завершения ивента.
+func handleCloseEvent(bot Messenger, message *tgbotapi.Message, args string) {
+	id, err := primitive.ObjectIDFromHex(strings.Fields(args)[0])
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат ID ивента."))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	event, err := store.GetEvent(ctx, id)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ивент не найден."))
+		return
+	}
+
+	summary, err := closeEvent(bot, id, message.From.ID)
+	if errors.Is(err, storage.ErrEventClosed) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Этот ивент уже завершён."))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при завершении ивента."))
+		return
+	}
+	// Итоги уже опубликованы в чате объявления; в другой чат отправляем их копию.
+	if event.Announcement.MessageID == 0 || event.Announcement.ChatID != message.Chat.ID {
+		sendLong(bot, message.Chat.ID, summary)
+	}
+}
+
+// RunEventScheduler публикует запланированные ивенты, рассылает напоминания, завершает
+// истёкшие ивенты и просроченные предложения обмена, пока не будет отменён ctx. Всё состояние
+// хранится в базе, поэтому пропущенное за время простоя бота выполняется при запуске.
+func RunEventScheduler(ctx context.Context, bot Messenger) {
+	ticker := time.NewTicker(eventSchedulerInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		runEventSchedule(ctx, bot, now)
+		expireTrades(ctx, bot, now)
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
 		}
-		// Считываем обновленный профиль.
-		err = userCollection.FindOne(ctx, filter).Decode(&profile)
+	}
+}
+
+// runEventSchedule выполняет один проход планировщика на момент now.
+func runEventSchedule(ctx context.Context, bot Messenger, now time.Time) {
+	listCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	scheduled, err := store.ListEvents(listCtx, models.EventScheduled)
+	if err != nil {
+		log.Printf("Ошибка получения запланированных ивентов: %v", err)
+	}
+	for _, event := range scheduled {
+		if event.StartDate.After(now) {
+			continue
+		}
+		opened, err := store.OpenEvent(listCtx, event.ID)
 		if err != nil {
-			bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Ошибка получения обновленных данных профиля."))
+			if !errors.Is(err, storage.ErrEventStarted) {
+				log.Printf("Ошибка открытия ивента %s: %v", event.ID.Hex(), err)
+			}
+			continue
+		}
+		announceEvent(bot, opened)
+	}
+
+	active, err := store.ListEvents(listCtx, models.EventActive)
+	if err != nil {
+		log.Printf("Ошибка получения активных ивентов: %v", err)
+		return
+	}
+	for i := range active {
+		event := &active[i]
+		if event.Expired(now) {
+			if _, err := closeEvent(bot, event.ID, 0); err != nil && !errors.Is(err, storage.ErrEventClosed) {
+				log.Printf("Ошибка автоматического завершения ивента %s: %v", event.ID.Hex(), err)
+			}
+			continue
+		}
+		if remindAt := eventReminderTime(event); !event.ReminderSent && !remindAt.IsZero() && !now.Before(remindAt) {
+			sendEventReminders(listCtx, bot, event)
+		}
+	}
+}
+
+// sendEventReminders напоминает зарегистрированным игрокам, не ответившим на ивент,
+// что он скоро завершится. Напоминание по ивенту рассылается один раз.
+func sendEventReminders(ctx context.Context, bot Messenger, event *models.Event) {
+	marked, err := store.MarkEventReminded(ctx, event.ID)
+	if err != nil || !marked {
+		if err != nil {
+			log.Printf("Ошибка отметки напоминания по ивенту %s: %v", event.ID.Hex(), err)
+		}
+		return
+	}
+	participants, err := store.ListParticipants(ctx, event.ID)
+	if err != nil {
+		log.Printf("Ошибка получения участников ивента %s: %v", event.ID.Hex(), err)
+		return
+	}
+	responded := make(map[int64]bool, len(participants))
+	for _, p := range participants {
+		responded[p.TelegramID] = true
+	}
+	profiles, err := store.ListProfiles(ctx, storage.SortNone)
+	if err != nil {
+		log.Printf("Ошибка получения анкет для напоминания: %v", err)
+		return
+	}
+
+	text := fmt.Sprintf("Напоминание: ивент '%s' завершится %s.\nОбломков: %d, пиастр: %d. Вы ещё не ответили — участвуете?",
+		event.Name, event.EndsAt.Format("02.01.2006 15:04"), event.Oblomki, event.Piastry)
+	for _, profile := range profiles {
+		if responded[profile.TelegramID] {
+			continue
+		}
+		// Кнопки в личном сообщении работают так же, как в объявлении.
+		msg := tgbotapi.NewMessage(profile.TelegramID, text)
+		msg.ReplyMarkup = eventKeyboard(event.ID)
+		if _, err := bot.Send(msg); err != nil {
+			// Игрок мог ещё не начать диалог с ботом.
+			log.Printf("Не удалось отправить напоминание игроку %d: %v", profile.TelegramID, err)
+		}
+	}
+}
+
+// handleListEvents выводит активные и запланированные ивенты с их ID.
+func handleListEvents(bot Messenger, message *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := store.ListEvents(ctx, models.EventActive)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении списка ивентов."))
+		return
+	}
+	scheduled, err := store.ListEvents(ctx, models.EventScheduled)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении списка ивентов."))
+		return
+	}
+	if len(events) == 0 && len(scheduled) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Нет активных ивентов."))
+		return
+	}
+	var result strings.Builder
+	writeEvents(&result, "Активные ивенты:\n", events)
+	if len(events) > 0 && len(scheduled) > 0 {
+		result.WriteString("\n")
+	}
+	writeEvents(&result, "Запланированные ивенты:\n", scheduled)
+	sendLong(bot, message.Chat.ID, result.String())
+}
+
+// writeEvents добавляет в result заголовок и строки со списком ивентов (ничего, если список пуст).
+func writeEvents(result *strings.Builder, header string, events []models.Event) {
+	if len(events) == 0 {
+		return
+	}
+	result.WriteString(header)
+	for _, event := range events {
+		result.WriteString(fmt.Sprintf("ID: %s | %s | Обломки: %d | Пиастры: %d | Начало: %s",
+			event.ID.Hex(), event.Name, event.Oblomki, event.Piastry, event.StartDate.Format("02.01.2006 15:04")))
+		if !event.EndsAt.IsZero() {
+			result.WriteString(" | Завершится: " + event.EndsAt.Format("02.01.2006 15:04"))
+		}
+		result.WriteString("\n")
+	}
+}
+
+// handleEventRoster выводит ответы игроков на ивент. Без аргумента берётся последний активный ивент.
+func handleEventRoster(bot Messenger, message *tgbotapi.Message, args string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var event *models.Event
+	if args == "" {
+		events, err := store.ListEvents(ctx, models.EventActive)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении списка ивентов."))
 			return
 		}
-		// Формируем строку с данными анкеты и информацией об ивенте.
-		caption := fmt.Sprintf(
-			"Имя: %s\nРаса: %s\nВозраст: %s\nРост и вес: %s\nПол: %s\nРанг: %s\nКоманда: %s\nОбломки: %d\nПиастры: %d\nИнвентарь: %s\n\nДата ивента: %s\nСтатус: Участвует",
-			profile.Name, profile.Race, profile.Age, profile.HeightWeight,
-			profile.Gender, profile.Rank, profile.Team, profile.Oblomki,
-			profile.Piastry, profile.Inventory, currentEvent.StartDate.Format("02.01.2006 15:04"))
-		// Отправляем фото с подписью.
-		photoMsg := tgbotapi.NewPhoto(cq.Message.Chat.ID, tgbotapi.FileID(profile.PhotoFileID))
-		photoMsg.Caption = caption
-		bot.Send(photoMsg)
-	} else if cq.Data == "event:skip" {
-		// Опция «Пропуск»: баланс не обновляем, выводим статус пропуска.
-		caption := fmt.Sprintf(
-			"Имя: %s\nРаса: %s\nВозраст: %s\nРост и вес: %s\nПол: %s\nРанг: %s\nКоманда: %s\nОбломки: %d\nПиастры: %d\nИнвентарь: %s\n\nДата ивента: %s\nСтатус: Пропускает ивент",
-			profile.Name, profile.Race, profile.Age, profile.HeightWeight,
-			profile.Gender, profile.Rank, profile.Team, profile.Oblomki,
-			profile.Piastry, profile.Inventory, currentEvent.StartDate.Format("02.01.2006 15:04"))
-		photoMsg := tgbotapi.NewPhoto(cq.Message.Chat.ID, tgbotapi.FileID(profile.PhotoFileID))
-		photoMsg.Caption = caption
-		bot.Send(photoMsg)
+		if len(events) == 0 {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Нет активных ивентов. Укажите ID ивента: участники ивента (ID)"))
+			return
+		}
+		event = &events[0]
 	} else {
-		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Неверный выбор."))
+		id, err := primitive.ObjectIDFromHex(strings.Fields(args)[0])
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат ID ивента."))
+			return
+		}
+		if event, err = store.GetEvent(ctx, id); err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ивент не найден."))
+			return
+		}
 	}
+
+	participants, err := store.ListParticipants(ctx, event.ID)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении участников ивента."))
+		return
+	}
+	var joined, skipped strings.Builder
+	for _, p := range participants {
+		line := fmt.Sprintf("• %s (@%s) — %s\n", p.Name, p.Username, p.RespondedAt.Format("02.01.2006 15:04"))
+		if p.Choice == models.ChoiceParticipate {
+			joined.WriteString(line)
+		} else {
+			skipped.WriteString(line)
+		}
+	}
+	joinedCount, skippedCount := countResponses(participants)
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Ивент '%s' (ID: %s)\n\n", event.Name, event.ID.Hex()))
+	result.WriteString(fmt.Sprintf("Участвуют (%d):\n%s\n", joinedCount, joined.String()))
+	result.WriteString(fmt.Sprintf("Пропускают (%d):\n%s", skippedCount, skipped.String()))
+	sendLong(bot, message.Chat.ID, result.String())
 }
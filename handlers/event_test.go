@@ -0,0 +1,41 @@
+package handlers_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"telegram-bot-go/handlers"
+	"telegram-bot-go/models"
+)
+
+func TestCreateEventRewards(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		created bool
+	}{
+		{"отрицательные обломки", "начатьивент Шторм, -5, 10", "Ошибка: число для обломков указано неверно", false},
+		{"отрицательные пиастры", "начатьивент Шторм, 5, -10", "Ошибка: число для пиастр указано неверно", false},
+		{"не число", "начатьивент Шторм, много, 10", "Ошибка: число для обломков указано неверно", false},
+		{"нулевая награда", "начатьивент Шторм, 0, 10", "Ивент 'Шторм' запущен!", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, bot := setup(t)
+			handlers.HandleCommand(bot, groupMessage(ownerID, tt.args))
+			texts := bot.Texts()
+			if len(texts) == 0 || !strings.Contains(texts[0], tt.want) {
+				t.Errorf("ответ %q, ожидался фрагмент %q", texts, tt.want)
+			}
+			events, err := store.ListEvents(context.Background(), models.EventActive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created := len(events) > 0; created != tt.created {
+				t.Errorf("ивент создан: %v, ожидалось %v", created, tt.created)
+			}
+		})
+	}
+}
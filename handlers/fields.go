@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// fieldNameLimit — максимальная длина названия дополнительного поля анкеты.
+	fieldNameLimit = 32
+	// fieldTextLimit и fieldLongTextLimit — максимальная длина значений текстовых полей.
+	fieldTextLimit     = 200
+	fieldLongTextLimit = 2000
+	// fieldStepPrefix — префикс ключа ответа на дополнительное поле в диалоге регистрации.
+	fieldStepPrefix = "field:"
+)
+
+// fieldTypes — типы дополнительных полей по их названиям в командах.
+var fieldTypes = map[string]string{
+	"текст":         models.FieldText,
+	"длинный текст": models.FieldLongText,
+	"число":         models.FieldNumber,
+}
+
+// fieldTypeLabel возвращает название типа поля для вывода.
+func fieldTypeLabel(fieldType string) string {
+	for label, t := range fieldTypes {
+		if t == fieldType {
+			return label
+		}
+	}
+	return fieldType
+}
+
+// listCustomFields возвращает дополнительные поля анкеты. При ошибке хранилища поля пропускаются.
+func listCustomFields(ctx context.Context) []models.ProfileField {
+	fields, err := store.ListFields(ctx)
+	if err != nil {
+		log.Printf("Ошибка получения полей анкеты: %v", err)
+	}
+	return fields
+}
+
+// validateFieldValue проверяет значение дополнительного поля и приводит его к виду для хранения.
+func validateFieldValue(field models.ProfileField, value string) (string, error) {
+	switch field.Type {
+	case models.FieldNumber:
+		n, err := strconv.Atoi(strings.ReplaceAll(value, " ", ""))
+		if err != nil {
+			return "", fmt.Errorf("«%s» — целое число, например: 100.", field.Name)
+		}
+		return strconv.Itoa(n), nil
+	case models.FieldLongText:
+		if utf8.RuneCountInString(value) > fieldLongTextLimit {
+			return "", fmt.Errorf("«%s»: не больше %d символов.", field.Name, fieldLongTextLimit)
+		}
+	default:
+		if utf8.RuneCountInString(value) > fieldTextLimit {
+			return "", fmt.Errorf("«%s»: не больше %d символов.", field.Name, fieldTextLimit)
+		}
+	}
+	return value, nil
+}
+
+// fieldStep возвращает шаг диалога регистрации для дополнительного поля.
+func fieldStep(field models.ProfileField) conversationStep {
+	prompt := fmt.Sprintf("Введите «%s»:", field.Name)
+	if field.Type == models.FieldNumber {
+		prompt = fmt.Sprintf("Введите «%s» (число):", field.Name)
+	}
+	return conversationStep{
+		Key:      fieldStepPrefix + field.ID.Hex(),
+		Prompt:   prompt,
+		Optional: !field.Required,
+		Validate: func(value string) (string, error) { return validateFieldValue(field, value) },
+	}
+}
+
+// customFieldsText возвращает заполненные дополнительные поля анкеты строками "Название: значение".
+func customFieldsText(profile *models.UserProfile, fields []models.ProfileField) string {
+	var text strings.Builder
+	for _, field := range fields {
+		if value := profile.Fields[field.ID.Hex()]; value != "" {
+			text.WriteString("\n" + field.Name + ": " + value)
+		}
+	}
+	return text.String()
+}
+
+// findField ищет дополнительное поле по названию и сообщает пользователю, если его нет.
+func findField(ctx context.Context, bot Messenger, chatID int64, name string) (*models.ProfileField, bool) {
+	field, err := store.GetFieldByKey(ctx, models.ItemKey(name))
+	if errors.Is(err, storage.ErrNotFound) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Поле «%s» не найдено. Список полей: поля", strings.TrimSpace(name))))
+		return nil, false
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении поля анкеты."))
+		return nil, false
+	}
+	return field, true
+}
+
+// matchCustomField ищет дополнительное поле, названием которого начинается text,
+// и возвращает его вместе с остатком текста. Названия полей могут состоять из нескольких слов.
+func matchCustomField(ctx context.Context, text string) (*models.ProfileField, string, bool) {
+	key := models.ItemKey(text)
+	var best *models.ProfileField
+	for _, field := range listCustomFields(ctx) {
+		if (key == field.Key || strings.HasPrefix(key, field.Key+" ")) && (best == nil || len(field.Key) > len(best.Key)) {
+			f := field
+			best = &f
+		}
+	}
+	if best == nil {
+		return nil, "", false
+	}
+	words := len(strings.Fields(best.Key))
+	return best, strings.Join(strings.Fields(text)[words:], " "), true
+}
+
+// fieldFlagsText описывает обязательность поля и то, кто его заполняет.
+func fieldFlagsText(field models.ProfileField) string {
+	flags := "необязательное"
+	if field.Required {
+		flags = "обязательное"
+	}
+	if field.Editable {
+		return flags + ", заполняет игрок"
+	}
+	return flags + ", заполняет администрация"
+}
+
+// handleListFields выводит дополнительные поля анкеты.
+func handleListFields(bot Messenger, message *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fields, err := store.ListFields(ctx)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении полей анкеты."))
+		return
+	}
+	if len(fields) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Дополнительных полей анкеты нет."))
+		return
+	}
+	var text strings.Builder
+	text.WriteString("Дополнительные поля анкеты:\n")
+	for _, field := range fields {
+		text.WriteString(fmt.Sprintf("• %s — %s, %s\n", field.Name, fieldTypeLabel(field.Type), fieldFlagsText(field)))
+	}
+	text.WriteString("\nИзменить своё поле: изменить (название поля) (значение)")
+	sendLong(bot, message.Chat.ID, text.String())
+}
+
+// parseFieldFlags разбирает флаги поля: обязательное/необязательное и открытое/закрытое.
+// Незаданные флаги остаются прежними.
+func parseFieldFlags(words []string, required, editable *bool) error {
+	for _, word := range words {
+		switch models.ItemKey(word) {
+		case "":
+		case "обязательное":
+			*required = true
+		case "необязательное":
+			*required = false
+		case "открытое":
+			*editable = true
+		case "закрытое":
+			*editable = false
+		default:
+			return fmt.Errorf("неизвестный параметр «%s»", strings.TrimSpace(word))
+		}
+	}
+	return nil
+}
+
+// handleCreateField добавляет поле анкеты.
+// Формат: создать поле Название, тип[, обязательное][, закрытое].
+func handleCreateField(bot Messenger, message *tgbotapi.Message, args string) {
+	const usage = "Например: создать поле Награда за голову, число, закрытое. Типы: текст, длинный текст, число."
+	parts := strings.Split(args, ",")
+	name := strings.Join(strings.Fields(parts[0]), " ")
+	if name == "" || utf8.RuneCountInString(name) > fieldNameLimit || len(parts) < 2 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("Укажите название поля (не длиннее %d символов) и его тип. %s", fieldNameLimit, usage)))
+		return
+	}
+	fieldType, ok := fieldTypes[models.ItemKey(parts[1])]
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неизвестный тип поля. "+usage))
+		return
+	}
+	field := &models.ProfileField{
+		Key:       models.ItemKey(name),
+		Name:      name,
+		Type:      fieldType,
+		Editable:  true,
+		CreatedAt: time.Now(),
+		CreatedBy: message.From.ID,
+	}
+	if err := parseFieldFlags(parts[2:], &field.Required, &field.Editable); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: "+err.Error()+". "+usage))
+		return
+	}
+	if _, ok := profileFieldNames[field.Key]; ok {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("«%s» — стандартное поле анкеты.", name)))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := store.CreateField(ctx, field)
+	if errors.Is(err, storage.ErrFieldExists) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Поле «%s» уже есть.", name)))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при добавлении поля анкеты."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+		fmt.Sprintf("Поле «%s» (%s, %s) добавлено в анкету.", name, fieldTypeLabel(fieldType), fieldFlagsText(*field))))
+}
+
+// handleConfigureField меняет флаги поля анкеты. Формат: настроить поле Название, обязательное, закрытое.
+func handleConfigureField(bot Messenger, message *tgbotapi.Message, args string) {
+	const usage = "Например: настроить поле Происхождение, обязательное, открытое"
+	parts := strings.Split(args, ",")
+	if len(parts) < 2 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. "+usage))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	field, ok := findField(ctx, bot, message.Chat.ID, parts[0])
+	if !ok {
+		return
+	}
+	if err := parseFieldFlags(parts[1:], &field.Required, &field.Editable); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: "+err.Error()+". "+usage))
+		return
+	}
+	if err := store.SetFieldFlags(ctx, field.ID, field.Required, field.Editable); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении поля анкеты."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Поле «%s»: %s.", field.Name, fieldFlagsText(*field))))
+}
+
+// handleDeleteField удаляет поле анкеты вместе с его значениями во всех анкетах.
+func handleDeleteField(bot Messenger, message *tgbotapi.Message, args string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	field, ok := findField(ctx, bot, message.Chat.ID, args)
+	if !ok {
+		return
+	}
+	if err := store.DeleteField(ctx, field.ID); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при удалении поля анкеты."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Поле «%s» удалено из анкеты.", field.Name)))
+}
+
+// handleFillField задаёт значение поля в анкете игрока; пустое значение очищает поле.
+// Формат: заполнить поле @username Название, значение.
+func handleFillField(bot Messenger, message *tgbotapi.Message, args string) {
+	ref, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	name, value, ok := strings.Cut(rest, ",")
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: заполнить поле @username Награда за голову, 500"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	target, err := findProfileByRef(ctx, ref)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Анкета %s не найдена.", ref)))
+		return
+	}
+	field, ok := findField(ctx, bot, message.Chat.ID, name)
+	if !ok {
+		return
+	}
+	if value = strings.TrimSpace(value); value != "" {
+		if value, err = validateFieldValue(*field, value); err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
+			return
+		}
+	}
+	if err := store.SetProfileFields(ctx, target.TelegramID, map[string]interface{}{"fields." + field.ID.Hex(): value}); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении анкеты."))
+		return
+	}
+	if value == "" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Поле «%s» у @%s очищено.", field.Name, target.Username)))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Поле «%s» у @%s: %s", field.Name, target.Username, value)))
+}
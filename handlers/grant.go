@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// grantRequestText формирует описание заявки для администрации.
+func grantRequestText(request *models.GrantRequest) string {
+	return fmt.Sprintf("Заявка на пополнение\nИгрок: %s (@%s)\nРесурс: %s\nКоличество: %d\nДата: %s",
+		request.Name, request.Username, request.ResourceLabel, request.Amount,
+		request.CreatedAt.Format("02.01.2006 15:04"))
+}
+
+// notifyAdminsAboutGrant рассылает администрации и казначеям заявку с кнопками "Одобрить" и "Отклонить"
+// и запоминает отправленные сообщения, чтобы после решения убрать кнопки у всех.
+func notifyAdminsAboutGrant(bot Messenger, request *models.GrantRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ids, err := roleHolderIDs(ctx, rolesTreasury...)
+	if err != nil {
+		log.Printf("Ошибка получения списка администраторов: %v", err)
+	}
+
+	id := request.ID.Hex()
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Одобрить", "grant:"+id+":approve"),
+			tgbotapi.NewInlineKeyboardButtonData("Отклонить", "grant:"+id+":reject"),
+		),
+	)
+	var refs []models.MessageRef
+	for _, adminID := range ids {
+		msg := tgbotapi.NewMessage(adminID, grantRequestText(request))
+		msg.ReplyMarkup = keyboard
+		sent, err := bot.Send(msg)
+		if err != nil {
+			// Администратор мог ещё не начать диалог с ботом.
+			log.Printf("Не удалось отправить заявку администратору %d: %v", adminID, err)
+			continue
+		}
+		refs = append(refs, models.MessageRef{ChatID: sent.Chat.ID, MessageID: sent.MessageID})
+	}
+	if err := store.SetGrantNotifications(ctx, request.ID, refs); err != nil {
+		log.Printf("Ошибка сохранения уведомлений по заявке %s: %v", id, err)
+	}
+}
+
+// HandleGrantCallback обрабатывает нажатие "Одобрить"/"Отклонить" по заявке.
+// Формат данных: grant:<id заявки>:approve или grant:<id заявки>:reject.
+func HandleGrantCallback(bot Messenger, cq *tgbotapi.CallbackQuery) {
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 3 {
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Неверный выбор."))
+		return
+	}
+	if !hasRole(cq.From.ID, rolesTreasury) {
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "У вас нет прав для выполнения этой команды."))
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Неверный формат айди заявки."))
+		return
+	}
+	var status, verdict string
+	switch parts[2] {
+	case "approve":
+		status, verdict = models.GrantApproved, "одобрена"
+	case "reject":
+		status, verdict = models.GrantRejected, "отклонена"
+	default:
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Неверный выбор."))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Одобрение меняет статус заявки и баланс одной транзакцией: если зачислить не удалось,
+	// заявка остаётся в ожидании и её можно рассмотреть снова.
+	var request *models.GrantRequest
+	var profile *models.UserProfile
+	if status == models.GrantApproved {
+		request, profile, err = store.ApproveGrantRequest(ctx, id, cq.From.ID)
+	} else {
+		request, err = store.DecideGrantRequest(ctx, id, status, cq.From.ID)
+	}
+	switch {
+	case errors.Is(err, storage.ErrAlreadyDecided):
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Эта заявка уже рассмотрена."))
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		if _, err := store.GetGrantRequest(ctx, id); err == nil {
+			bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Анкета игрока не найдена, зачислить ресурс нельзя. Заявку можно отклонить."))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Заявка не найдена."))
+		return
+	case err != nil:
+		log.Printf("Ошибка рассмотрения заявки %s: %v", id.Hex(), err)
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Ошибка при рассмотрении заявки. Попробуйте ещё раз."))
+		return
+	}
+	if profile != nil {
+		AddLogEvent(*profile, request.Amount, request.ResourceLabel)
+	}
+
+	// Убираем кнопки у всех администраторов и показываем, кто принял решение.
+	decidedBy := cq.From.UserName
+	if decidedBy == "" {
+		decidedBy = cq.From.FirstName
+	}
+	text := fmt.Sprintf("%s\n\nЗаявка %s (%s)", grantRequestText(request), verdict, decidedBy)
+	for _, ref := range request.Notifications {
+		bot.Send(tgbotapi.NewEditMessageText(ref.ChatID, ref.MessageID, text))
+	}
+	if len(request.Notifications) == 0 {
+		bot.Send(tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, text))
+	}
+
+	reply := fmt.Sprintf("Ваша заявка на %d %s %s.", request.Amount, request.ResourceLabel, verdict)
+	bot.Send(tgbotapi.NewMessage(request.TelegramID, reply))
+}
+
+// handleMyGrants выводит заявки пользователя, ожидающие рассмотрения.
+func handleMyGrants(bot Messenger, message *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	requests, err := store.ListGrantRequests(ctx, message.From.ID, models.GrantPending)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении заявок."))
+		return
+	}
+	if len(requests) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "У вас нет заявок на рассмотрении."))
+		return
+	}
+	var result strings.Builder
+	result.WriteString("Ваши заявки на рассмотрении:\n")
+	for _, request := range requests {
+		result.WriteString(fmt.Sprintf("• %s — %s: %d\n",
+			request.CreatedAt.Format("02.01.2006 15:04"), request.ResourceLabel, request.Amount))
+	}
+	sendLong(bot, message.Chat.ID, result.String())
+}
@@ -0,0 +1,168 @@
+// Package handlerstest содержит вспомогательные средства для тестирования обработчиков.
+package handlerstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Sent описывает одно исходящее сообщение, отправленное через RecordingMessenger.
+type Sent struct {
+	ChatID   int64
+	Text     string // текст сообщения или подпись к фото
+	PhotoID  string // FileID фотографии, если это фото
+	IsPhoto  bool
+	IsFile   bool   // документ; содержимое — в File
+	File     []byte // содержимое документа, отправленного из памяти
+	IsEdit   bool   // редактирование ранее отправленного сообщения
+	EditedID int    // ID редактируемого сообщения
+	Keyboard *tgbotapi.InlineKeyboardMarkup
+	Raw      tgbotapi.Chattable
+}
+
+// RecordingMessenger запоминает все отправленные сообщения и запросы вместо обращения к Telegram.
+type RecordingMessenger struct {
+	mu       sync.Mutex
+	sent     []Sent
+	requests []tgbotapi.Chattable
+	files    map[string]string // пути файлов по FileID для запросов getFile
+	nextID   int
+}
+
+// NewRecordingMessenger создаёт пустой RecordingMessenger.
+func NewRecordingMessenger() *RecordingMessenger {
+	return &RecordingMessenger{}
+}
+
+// Send записывает сообщение и возвращает его как успешно отправленное.
+func (m *RecordingMessenger) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	s := Sent{Raw: c}
+	switch msg := c.(type) {
+	case tgbotapi.MessageConfig:
+		s.ChatID = msg.ChatID
+		s.Text = msg.Text
+		s.Keyboard = inlineKeyboard(msg.ReplyMarkup)
+	case tgbotapi.PhotoConfig:
+		s.ChatID = msg.ChatID
+		s.Text = msg.Caption
+		s.IsPhoto = true
+		if id, ok := msg.File.(tgbotapi.FileID); ok {
+			s.PhotoID = string(id)
+		}
+		s.Keyboard = inlineKeyboard(msg.ReplyMarkup)
+	case tgbotapi.DocumentConfig:
+		s.ChatID = msg.ChatID
+		s.Text = msg.Caption
+		s.IsFile = true
+		if file, ok := msg.File.(tgbotapi.FileBytes); ok {
+			s.File = file.Bytes
+		}
+	case tgbotapi.EditMessageTextConfig:
+		s.ChatID = msg.ChatID
+		s.Text = msg.Text
+		s.IsEdit = true
+		s.EditedID = msg.MessageID
+		s.Keyboard = msg.ReplyMarkup
+	}
+	m.sent = append(m.sent, s)
+	sent := tgbotapi.Message{MessageID: m.nextID, Chat: &tgbotapi.Chat{ID: s.ChatID}}
+	if s.IsPhoto {
+		// Загруженная фотография получает новый FileID, как в ответе Telegram.
+		id := s.PhotoID
+		if id == "" {
+			id = fmt.Sprintf("uploaded-%d", m.nextID)
+		}
+		sent.Photo = []tgbotapi.PhotoSize{{FileID: id}}
+	}
+	return sent, nil
+}
+
+// SetFile задаёт путь файла fileID, который вернёт запрос getFile.
+func (m *RecordingMessenger) SetFile(fileID, path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.files == nil {
+		m.files = make(map[string]string)
+	}
+	m.files[fileID] = path
+}
+
+// Request записывает запрос (например, ответ на callback) и возвращает успешный ответ.
+// На запрос getFile отвечает путём, заданным через SetFile.
+func (m *RecordingMessenger) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, c)
+	if file, ok := c.(tgbotapi.FileConfig); ok {
+		path, known := m.files[file.FileID]
+		if !known {
+			return nil, fmt.Errorf("файл %s не найден", file.FileID)
+		}
+		result, err := json.Marshal(tgbotapi.File{FileID: file.FileID, FilePath: path})
+		if err != nil {
+			return nil, err
+		}
+		return &tgbotapi.APIResponse{Ok: true, Result: result}, nil
+	}
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// Sent возвращает копию всех отправленных сообщений в порядке отправки.
+func (m *RecordingMessenger) Sent() []Sent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Sent(nil), m.sent...)
+}
+
+// Texts возвращает тексты (или подписи) всех отправленных сообщений.
+func (m *RecordingMessenger) Texts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	texts := make([]string, len(m.sent))
+	for i, s := range m.sent {
+		texts[i] = s.Text
+	}
+	return texts
+}
+
+// Last возвращает последнее отправленное сообщение и false, если сообщений не было.
+func (m *RecordingMessenger) Last() (Sent, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		return Sent{}, false
+	}
+	return m.sent[len(m.sent)-1], true
+}
+
+// Requests возвращает все запросы, переданные через Request.
+func (m *RecordingMessenger) Requests() []tgbotapi.Chattable {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]tgbotapi.Chattable(nil), m.requests...)
+}
+
+// Reset очищает записанные сообщения и запросы.
+func (m *RecordingMessenger) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+	m.requests = nil
+}
+
+// inlineKeyboard извлекает инлайн-клавиатуру из ReplyMarkup, если она задана.
+func inlineKeyboard(markup interface{}) *tgbotapi.InlineKeyboardMarkup {
+	switch kb := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		return &kb
+	case *tgbotapi.InlineKeyboardMarkup:
+		return kb
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// itemNameLimit — максимальная длина названия предмета.
+const itemNameLimit = 64
+
+// itemNames возвращает названия предметов каталога по их идентификаторам.
+func itemNames(ctx context.Context) map[primitive.ObjectID]string {
+	names := make(map[primitive.ObjectID]string)
+	items, err := store.ListItems(ctx)
+	if err != nil {
+		log.Printf("Ошибка получения каталога предметов: %v", err)
+		return names
+	}
+	for _, item := range items {
+		names[item.ID] = item.Name
+	}
+	return names
+}
+
+// itemName возвращает название предмета; удалённые из каталога предметы подписываются отдельно.
+func itemName(names map[primitive.ObjectID]string, id primitive.ObjectID) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return "неизвестный предмет"
+}
+
+// inventorySummary формирует строку инвентаря для анкеты: "Меч ×2, Ром ×1 (заметка: ...)".
+func inventorySummary(profile *models.UserProfile, names map[primitive.ObjectID]string) string {
+	var parts []string
+	for _, item := range profile.Items {
+		parts = append(parts, fmt.Sprintf("%s ×%d", itemName(names, item.ItemID), item.Quantity))
+	}
+	summary := strings.Join(parts, ", ")
+	if summary == "" {
+		summary = "Пусто"
+	}
+	if profile.InventoryNote != "" {
+		summary += " (заметка: " + profile.InventoryNote + ")"
+	}
+	return summary
+}
+
+// findProfileByRef ищет анкету по @username или ID анкеты.
+func findProfileByRef(ctx context.Context, ref string) (*models.UserProfile, error) {
+	if strings.HasPrefix(ref, "@") {
+		return store.GetProfileByUsername(ctx, strings.ToLower(strings.TrimPrefix(ref, "@")))
+	}
+	id, err := primitive.ObjectIDFromHex(ref)
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	return store.GetProfileByID(ctx, id)
+}
+
+// parseItemAmount разбирает аргументы вида "Название[, количество]". По умолчанию количество — 1.
+func parseItemAmount(args string) (name string, quantity int, err error) {
+	name, quantity = args, 1
+	if i := strings.LastIndex(args, ","); i >= 0 {
+		quantity, err = strconv.Atoi(strings.TrimSpace(args[i+1:]))
+		if err != nil || quantity <= 0 {
+			return "", 0, errors.New("неверное количество")
+		}
+		name = args[:i]
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", 0, errors.New("не указан предмет")
+	}
+	return name, quantity, nil
+}
+
+// findItem ищет предмет в каталоге по названию и сообщает, если его нет.
+func findItem(ctx context.Context, bot Messenger, chatID int64, name string) (*models.Item, bool) {
+	item, err := store.GetItemByKey(ctx, models.ItemKey(name))
+	if errors.Is(err, storage.ErrNotFound) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Предмет «%s» не найден в каталоге. Список предметов: предметы", name)))
+		return nil, false
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении предмета."))
+		return nil, false
+	}
+	return item, true
+}
+
+// handleInventory выводит инвентарь: свой (без аргументов) или игрока по @username или ID анкеты.
+func handleInventory(bot Messenger, message *tgbotapi.Message, args string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var profile *models.UserProfile
+	var err error
+	if args == "" {
+		profile, err = store.GetProfile(ctx, message.From.ID)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Анкета не найдена. Зарегистрируйтесь командой: регистрация"))
+			return
+		}
+	} else {
+		profile, err = findProfileByRef(ctx, strings.Fields(args)[0])
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Анкета не найдена."))
+			return
+		}
+	}
+
+	items, err := store.ListItems(ctx)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении каталога предметов."))
+		return
+	}
+	catalog := make(map[primitive.ObjectID]models.Item, len(items))
+	for _, item := range items {
+		catalog[item.ID] = item
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Инвентарь %s (@%s):\n", profile.Name, profile.Username))
+	for _, entry := range profile.Items {
+		item, ok := catalog[entry.ItemID]
+		if !ok {
+			result.WriteString(fmt.Sprintf("• неизвестный предмет ×%d\n", entry.Quantity))
+			continue
+		}
+		line := fmt.Sprintf("• %s ×%d", item.Name, entry.Quantity)
+		if item.Description != "" {
+			line += " — " + item.Description
+		}
+		result.WriteString(line + "\n")
+	}
+	if len(profile.Items) == 0 {
+		result.WriteString("Пусто\n")
+	}
+	if profile.InventoryNote != "" {
+		result.WriteString("\nЗаметка: " + profile.InventoryNote + "\n")
+	}
+	sendLong(bot, message.Chat.ID, result.String())
+}
+
+// handleItemCatalog выводит каталог предметов постранично.
+func handleItemCatalog(bot Messenger, message *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := loadItemsPage(ctx, "")
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении каталога предметов."))
+		return
+	}
+	sendPaged(bot, message.Chat.ID, "items", "", list)
+}
+
+// loadItemsPage формирует каталог предметов для постраничного вывода.
+func loadItemsPage(ctx context.Context, _ string) (pagedList, error) {
+	items, err := store.ListItems(ctx)
+	if err != nil {
+		return pagedList{}, err
+	}
+	list := pagedList{Header: "Каталог предметов:\n", Empty: "Каталог предметов пуст."}
+	for _, item := range items {
+		line := "• " + item.Name
+		if item.Description != "" {
+			line += " — " + item.Description
+		}
+		list.Lines = append(list.Lines, line)
+	}
+	return list, nil
+}
+
+// handleCreateItem добавляет предмет в каталог. Формат: создать предмет Название[, описание].
+func handleCreateItem(bot Messenger, message *tgbotapi.Message, args string) {
+	name, description, _ := strings.Cut(args, ",")
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || utf8.RuneCountInString(name) > itemNameLimit {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("Укажите название предмета (не длиннее %d символов). Например: создать предмет Ром, бутылка ямайского рома", itemNameLimit)))
+		return
+	}
+	item := &models.Item{
+		Key:         models.ItemKey(name),
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		CreatedAt:   time.Now(),
+		CreatedBy:   message.From.ID,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := store.CreateItem(ctx, item)
+	if errors.Is(err, storage.ErrItemExists) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Предмет «%s» уже есть в каталоге.", name)))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при добавлении предмета."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Предмет «%s» добавлен в каталог.", name)))
+}
+
+// handleDescribeItem меняет описание предмета. Формат: описать предмет Название, описание.
+func handleDescribeItem(bot Messenger, message *tgbotapi.Message, args string) {
+	name, description, ok := strings.Cut(args, ",")
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: описать предмет Ром, бутылка ямайского рома"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item, ok := findItem(ctx, bot, message.Chat.ID, name)
+	if !ok {
+		return
+	}
+	if err := store.SetItemDescription(ctx, item.ID, strings.TrimSpace(description)); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении предмета."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Описание предмета «%s» изменено.", item.Name)))
+}
+
+// handleDeleteItem удаляет предмет из каталога, если его нет ни у одного игрока.
+func handleDeleteItem(bot Messenger, message *tgbotapi.Message, args string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item, ok := findItem(ctx, bot, message.Chat.ID, args)
+	if !ok {
+		return
+	}
+	if _, err := store.GetListingByItem(ctx, item.ID); err == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("Предмет «%s» продаётся в магазине. Сначала снимите его с продажи: снять с продажи %s", item.Name, item.Name)))
+		return
+	}
+	err := store.DeleteItem(ctx, item.ID)
+	if errors.Is(err, storage.ErrItemInUse) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("Предмет «%s» есть в инвентаре игроков. Сначала изымите его командой: изъять предмет", item.Name)))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при удалении предмета."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Предмет «%s» удалён из каталога.", item.Name)))
+}
+
+// handleGiveItem выдаёт предметы игроку. Формат: выдать предмет @username Название[, количество].
+func handleGiveItem(bot Messenger, message *tgbotapi.Message, args string) {
+	changePlayerItems(bot, message, args, 1)
+}
+
+// handleTakeItem изымает предметы у игрока. Формат: изъять предмет @username Название[, количество].
+func handleTakeItem(bot Messenger, message *tgbotapi.Message, args string) {
+	changePlayerItems(bot, message, args, -1)
+}
+
+// changePlayerItems выдаёт (sign = 1) или изымает (sign = -1) предметы по команде администрации
+// и сообщает об этом игроку.
+func changePlayerItems(bot Messenger, message *tgbotapi.Message, args string, sign int) {
+	ref, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	name, quantity, err := parseItemAmount(rest)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: "+err.Error()+". Например: выдать предмет @username Ром, 2"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	target, err := findProfileByRef(ctx, ref)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Анкета %s не найдена.", ref)))
+		return
+	}
+	item, ok := findItem(ctx, bot, message.Chat.ID, name)
+	if !ok {
+		return
+	}
+	updated, err := store.AdjustItem(ctx, target.TelegramID, item.ID, sign*quantity)
+	if errors.Is(err, storage.ErrInsufficientItems) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("У @%s только %d × %s.", target.Username, target.ItemQuantity(item.ID), item.Name)))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении инвентаря."))
+		return
+	}
+	AddLogEvent(*updated, sign*quantity, item.Name)
+
+	left := updated.ItemQuantity(item.ID)
+	if sign > 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("@%s выдано: %s ×%d. Теперь у игрока: %d.", updated.Username, item.Name, quantity, left)))
+		bot.Send(tgbotapi.NewMessage(updated.TelegramID, fmt.Sprintf("Вам выдано: %s ×%d.", item.Name, quantity)))
+	} else {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("У @%s изъято: %s ×%d. Осталось: %d.", updated.Username, item.Name, quantity, left)))
+		bot.Send(tgbotapi.NewMessage(updated.TelegramID, fmt.Sprintf("У вас изъято: %s ×%d.", item.Name, quantity)))
+	}
+}
+
+// handleUseItem списывает использованные игроком предметы. Формат: использовать Название[, количество].
+func handleUseItem(bot Messenger, message *tgbotapi.Message, args string) {
+	spendOwnItems(bot, message, args, "Вы использовали")
+}
+
+// handleDiscardItem списывает выброшенные игроком предметы. Формат: выбросить Название[, количество].
+func handleDiscardItem(bot Messenger, message *tgbotapi.Message, args string) {
+	spendOwnItems(bot, message, args, "Вы выбросили")
+}
+
+// spendOwnItems списывает предметы из инвентаря самого игрока; done начинает ответ об успехе.
+func spendOwnItems(bot Messenger, message *tgbotapi.Message, args, done string) {
+	name, quantity, err := parseItemAmount(args)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: "+err.Error()+". Например: использовать Ром, 1"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item, ok := findItem(ctx, bot, message.Chat.ID, name)
+	if !ok {
+		return
+	}
+	updated, err := store.AdjustItem(ctx, message.From.ID, item.ID, -quantity)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Анкета не найдена. Зарегистрируйтесь командой: регистрация"))
+		return
+	case errors.Is(err, storage.ErrInsufficientItems):
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("У вас недостаточно предметов «%s». Посмотреть инвентарь: инвентарь", item.Name)))
+		return
+	case err != nil:
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении инвентаря."))
+		return
+	}
+	AddLogEvent(*updated, -quantity, item.Name)
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+		fmt.Sprintf("%s: %s ×%d. Осталось: %d.", done, item.Name, quantity, updated.ItemQuantity(item.ID))))
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// logTotalsLimit — сколько строк итогов выводится в сообщении.
+const logTotalsLimit = 15
+
+// Названия ресурсов в логах и их короткие коды для данных кнопок.
+var logResourceCodes = map[string]string{"обломки": "o", "пиастры": "p"}
+
+// checkLogUsage — подсказка по формату команды "чек лог".
+const checkLogUsage = "Используйте: чек лог [день/неделя/месяц] [@username или ID анкеты] [обломки/пиастры] [+/-] [с ДД.ММ.ГГГГ] [по ДД.ММ.ГГГГ] [csv]"
+
+// parseLogFilter разбирает аргументы команды "чек лог". Фильтры указываются в любом порядке.
+// Если конец периода не задан, он фиксируется текущим моментом, чтобы страницы не сдвигались.
+func parseLogFilter(ctx context.Context, args string, now time.Time) (filter storage.LogFilter, asCSV bool, err error) {
+	fields := strings.Fields(args)
+	for i := 0; i < len(fields); i++ {
+		word := strings.ToLower(fields[i])
+		switch {
+		case word == "день":
+			filter.Since = now.Add(-24 * time.Hour)
+		case word == "неделя":
+			filter.Since = now.Add(-7 * 24 * time.Hour)
+		case word == "месяц":
+			filter.Since = now.Add(-30 * 24 * time.Hour)
+		case word == "csv":
+			asCSV = true
+		case word == "+" || word == "начисления":
+			filter.Sign = 1
+		case word == "-" || word == "списания":
+			filter.Sign = -1
+		case logResourceCodes[word] != "":
+			filter.Resource = word
+		case word == "с" || word == "по":
+			if i+1 >= len(fields) {
+				return filter, false, fmt.Errorf("после «%s» укажите дату", word)
+			}
+			i++
+			date, err := time.ParseInLocation("02.01.2006", fields[i], time.Local)
+			if err != nil {
+				return filter, false, fmt.Errorf("неверная дата: %s", fields[i])
+			}
+			if word == "с" {
+				filter.Since = date
+			} else {
+				// Дата "по" включается в период целиком.
+				filter.Until = date.AddDate(0, 0, 1)
+			}
+		case strings.HasPrefix(word, "@"):
+			profile, err := store.GetProfileByUsername(ctx, strings.TrimPrefix(word, "@"))
+			if err != nil {
+				return filter, false, fmt.Errorf("пользователь %s не найден", fields[i])
+			}
+			filter.TelegramID = profile.TelegramID
+		case primitive.IsValidObjectID(word):
+			id, _ := primitive.ObjectIDFromHex(word)
+			profile, err := store.GetProfileByID(ctx, id)
+			if err != nil {
+				return filter, false, fmt.Errorf("анкета %s не найдена", fields[i])
+			}
+			filter.TelegramID = profile.TelegramID
+		default:
+			return filter, false, fmt.Errorf("неизвестный фильтр: %s", fields[i])
+		}
+	}
+	if filter.Until.IsZero() {
+		// В данных кнопки время хранится с точностью до секунды, поэтому округляем вверх.
+		filter.Until = now.Truncate(time.Second).Add(time.Second)
+	}
+	return filter, asCSV, nil
+}
+
+// logState кодирует фильтр в состояние постраничного вывода:
+// <telegram id>:<код ресурса>:<знак>:<начало>:<конец> (время — в секундах Unix).
+// Так данные кнопки укладываются в ограничение Telegram в 64 байта.
+func logState(filter storage.LogFilter) string {
+	var since, until int64
+	if !filter.Since.IsZero() {
+		since = filter.Since.Unix()
+	}
+	if !filter.Until.IsZero() {
+		until = filter.Until.Unix()
+	}
+	return fmt.Sprintf("%d:%s:%d:%d:%d", filter.TelegramID, logResourceCodes[filter.Resource], filter.Sign, since, until)
+}
+
+// parseLogState разбирает состояние, сформированное logState.
+func parseLogState(state string) (storage.LogFilter, error) {
+	var filter storage.LogFilter
+	parts := strings.Split(state, ":")
+	if len(parts) != 5 {
+		return filter, errors.New("неверные данные страницы лога")
+	}
+	tid, err1 := strconv.ParseInt(parts[0], 10, 64)
+	sign, err2 := strconv.Atoi(parts[2])
+	since, err3 := strconv.ParseInt(parts[3], 10, 64)
+	until, err4 := strconv.ParseInt(parts[4], 10, 64)
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		return filter, err
+	}
+	for name, code := range logResourceCodes {
+		if code == parts[1] {
+			filter.Resource = name
+		}
+	}
+	filter.TelegramID = tid
+	filter.Sign = sign
+	if since > 0 {
+		filter.Since = time.Unix(since, 0)
+	}
+	if until > 0 {
+		filter.Until = time.Unix(until, 0)
+	}
+	return filter, nil
+}
+
+// logTotal — сумма изменений одного ресурса у одного пользователя.
+type logTotal struct {
+	Name     string
+	Username string
+	Resource string
+	Sum      int
+}
+
+// logTotals считает суммы изменений по пользователям и ресурсам, упорядоченные по имени и ресурсу.
+func logTotals(entries []models.LogEntry) []logTotal {
+	type key struct {
+		telegramID int64
+		resource   string
+	}
+	index := make(map[key]int)
+	var totals []logTotal
+	for _, entry := range entries {
+		k := key{entry.TelegramID, strings.ToLower(entry.Resource)}
+		i, ok := index[k]
+		if !ok {
+			i = len(totals)
+			index[k] = i
+			totals = append(totals, logTotal{Name: entry.Name, Username: entry.Username, Resource: k.resource})
+		}
+		totals[i].Sum += entry.ChangeAmount
+	}
+	sort.SliceStable(totals, func(i, j int) bool {
+		if totals[i].Name != totals[j].Name {
+			return totals[i].Name < totals[j].Name
+		}
+		return totals[i].Resource < totals[j].Resource
+	})
+	return totals
+}
+
+// logList формирует постраничный вывод лога: записи по строкам, итоги — в подвале каждой страницы.
+func logList(entries []models.LogEntry) pagedList {
+	list := pagedList{
+		Header: fmt.Sprintf("Лог изменений ресурсов: %d записей\n\n", len(entries)),
+		Empty:  "Нет логов за выбранный период.",
+	}
+	for _, entry := range entries {
+		list.Lines = append(list.Lines, fmt.Sprintf("%s, %s, @%s, %+d, %s",
+			entry.Date.Format("02.01.2006 15:04"), entry.Name, entry.Username, entry.ChangeAmount, entry.Resource))
+	}
+
+	var footer strings.Builder
+	totals := logTotals(entries)
+	footer.WriteString("\nИтого:\n")
+	for i, total := range totals {
+		if i == logTotalsLimit {
+			footer.WriteString(fmt.Sprintf("…и ещё %d строк (все записи — в выгрузке csv)\n", len(totals)-logTotalsLimit))
+			break
+		}
+		footer.WriteString(fmt.Sprintf("• %s (@%s): %s %+d\n", total.Name, total.Username, total.Resource, total.Sum))
+	}
+	list.Footer = footer.String()
+	return list
+}
+
+// loadLogPage загружает лог для листания страниц по состоянию из данных кнопки.
+func loadLogPage(ctx context.Context, state string) (pagedList, error) {
+	filter, err := parseLogState(state)
+	if err != nil {
+		return pagedList{}, err
+	}
+	entries, err := store.FindLogs(ctx, filter)
+	if err != nil {
+		return pagedList{}, err
+	}
+	return logList(entries), nil
+}
+
+// logCSV формирует CSV-файл с записями лога.
+func logCSV(entries []models.LogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"date", "telegram_id", "username", "name", "change_amount", "resource"})
+	for _, entry := range entries {
+		w.Write([]string{
+			entry.Date.Format("2006-01-02 15:04:05"),
+			strconv.FormatInt(entry.TelegramID, 10),
+			entry.Username,
+			entry.Name,
+			strconv.Itoa(entry.ChangeAmount),
+			entry.Resource,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// handleCheckLogCommand обрабатывает команду "чек лог" с фильтрами и выводит первую страницу лога
+// или отправляет выгрузку в CSV.
+func handleCheckLogCommand(bot Messenger, message *tgbotapi.Message, args string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter, asCSV, err := parseLogFilter(ctx, args, time.Now())
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: "+err.Error()+"\n"+checkLogUsage))
+		return
+	}
+	entries, err := store.FindLogs(ctx, filter)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении логов."))
+		return
+	}
+
+	if asCSV {
+		data, err := logCSV(entries)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при формировании CSV."))
+			return
+		}
+		doc := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{
+			Name:  "log-" + time.Now().Format("2006-01-02") + ".csv",
+			Bytes: data,
+		})
+		doc.Caption = fmt.Sprintf("Лог изменений ресурсов: %d записей", len(entries))
+		bot.Send(doc)
+		return
+	}
+
+	sendPaged(bot, message.Chat.ID, "log", logState(filter), logList(entries))
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/config"
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+)
+
+// logTestProfileID — идентификатор анкеты, по которому фильтр ищет игрока.
+var logTestProfileID = primitive.NewObjectID()
+
+// setupLogs подключает обработчики к хранилищу в памяти с анкетой @jack.
+func setupLogs(t *testing.T) {
+	t.Helper()
+	s := storage.NewMemory()
+	InitHandlers(s, &config.Config{})
+	err := s.SaveProfile(context.Background(), models.UserProfile{ID: logTestProfileID, TelegramID: 100, Username: "jack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseLogFilter(t *testing.T) {
+	setupLogs(t)
+	now := time.Date(2026, 5, 20, 15, 30, 10, 500, time.Local)
+	// Конец периода по умолчанию — текущая секунда с округлением вверх.
+	end := time.Date(2026, 5, 20, 15, 30, 11, 0, time.Local)
+	tests := []struct {
+		args    string
+		want    storage.LogFilter
+		wantCSV bool
+		wantErr string
+	}{
+		{args: "", want: storage.LogFilter{Until: end}},
+		{args: "день", want: storage.LogFilter{Since: now.Add(-24 * time.Hour), Until: end}},
+		{args: "Неделя", want: storage.LogFilter{Since: now.Add(-7 * 24 * time.Hour), Until: end}},
+		{args: "месяц csv", want: storage.LogFilter{Since: now.Add(-30 * 24 * time.Hour), Until: end}, wantCSV: true},
+		{args: "обломки +", want: storage.LogFilter{Resource: "обломки", Sign: 1, Until: end}},
+		{args: "списания Пиастры", want: storage.LogFilter{Resource: "пиастры", Sign: -1, Until: end}},
+		{args: "@jack", want: storage.LogFilter{TelegramID: 100, Until: end}},
+		{args: logTestProfileID.Hex() + " -", want: storage.LogFilter{TelegramID: 100, Sign: -1, Until: end}},
+		{
+			// Дата "по" входит в период целиком.
+			args: "с 01.05.2026 по 10.05.2026",
+			want: storage.LogFilter{
+				Since: time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local),
+				Until: time.Date(2026, 5, 11, 0, 0, 0, 0, time.Local),
+			},
+		},
+		{args: "с", wantErr: "после «с» укажите дату"},
+		{args: "по 31.02.2026", wantErr: "неверная дата: 31.02.2026"},
+		{args: "@anne", wantErr: "пользователь @anne не найден"},
+		{args: primitive.NewObjectID().Hex(), wantErr: "не найдена"},
+		{args: "вчера", wantErr: "неизвестный фильтр: вчера"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			filter, asCSV, err := parseLogFilter(context.Background(), tt.args, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ошибка %v, ожидалась %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalLogFilters(filter, tt.want) || asCSV != tt.wantCSV {
+				t.Errorf("фильтр %+v, csv %v; ожидались %+v, %v", filter, asCSV, tt.want, tt.wantCSV)
+			}
+		})
+	}
+}
+
+// equalLogFilters сравнивает фильтры, сравнивая время как моменты.
+func equalLogFilters(a, b storage.LogFilter) bool {
+	return a.TelegramID == b.TelegramID && a.Resource == b.Resource && a.Sign == b.Sign &&
+		a.Since.Equal(b.Since) && a.Until.Equal(b.Until)
+}
+
+func TestLogStateRoundTrip(t *testing.T) {
+	since := time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local)
+	until := time.Date(2026, 5, 11, 0, 0, 0, 0, time.Local)
+	tests := []storage.LogFilter{
+		{},
+		{Until: until},
+		{TelegramID: 100, Resource: "обломки", Sign: 1, Since: since, Until: until},
+		{TelegramID: 100, Resource: "пиастры", Sign: -1},
+	}
+	for _, filter := range tests {
+		state := logState(filter)
+		got, err := parseLogState(state)
+		if err != nil {
+			t.Fatalf("parseLogState(%q): %v", state, err)
+		}
+		if !equalLogFilters(got, filter) {
+			t.Errorf("после кодирования в %q фильтр %+v, ожидался %+v", state, got, filter)
+		}
+	}
+}
+
+func TestParseLogStateRejectsBadData(t *testing.T) {
+	for _, state := range []string{"", "100:o:1:0", "100:o:1:0:0:0", "x:o:1:0:0", "100:o:плюс:0:0", "100:o:1:вчера:0"} {
+		if _, err := parseLogState(state); err == nil {
+			t.Errorf("parseLogState(%q): ожидалась ошибка", state)
+		}
+	}
+}
+
+func TestLogStateFitsCallbackData(t *testing.T) {
+	// Самый длинный фильтр: наибольший ID, отрицательный знак и даты далеко в будущем.
+	filter := storage.LogFilter{
+		TelegramID: math.MaxInt64,
+		Resource:   "пиастры",
+		Sign:       -1,
+		Since:      time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+		Until:      time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+	data := pageData("log", 999, logState(filter))
+	if len(data) > callbackDataLimit {
+		t.Errorf("данные кнопки %q занимают %d байт, больше %d", data, len(data), callbackDataLimit)
+	}
+	got, err := parseLogState(strings.SplitN(data, ":", 4)[3])
+	if err != nil || !equalLogFilters(got, filter) {
+		t.Errorf("из данных кнопки разобран фильтр %+v (%v), ожидался %+v", got, err, filter)
+	}
+}
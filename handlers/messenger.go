@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Messenger — минимальный набор методов Telegram API, который нужен обработчикам.
+// Ему удовлетворяет *tgbotapi.BotAPI, а в тестах — handlerstest.RecordingMessenger.
+type Messenger interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+var _ Messenger = (*tgbotapi.BotAPI)(nil)
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// messageLimit — максимальная длина сообщения с запасом от ограничения Telegram в 4096 символов.
+	messageLimit = 4000
+	// pageLines — сколько строк списка выводится на одной странице.
+	pageLines = 20
+	// callbackDataLimit — ограничение Telegram на длину данных кнопки в байтах.
+	callbackDataLimit = 64
+	// captionLimit — ограничение Telegram на длину подписи к фотографии.
+	captionLimit = 1024
+)
+
+// pagedList — содержимое постраничного вывода. Заголовок и подвал повторяются на каждой странице.
+type pagedList struct {
+	Header  string
+	Lines   []string
+	Buttons []tgbotapi.InlineKeyboardButton // кнопки строк, по одной на строку; необязательно
+	Footer  string
+	Empty   string // текст, если строк нет
+}
+
+// pageSource загружает список для постраничного вывода по состоянию из данных кнопки.
+// Состояние хранится в самой кнопке, поэтому листать можно и после перезапуска бота.
+type pageSource struct {
+	Roles []string // кому доступно листание (см. hasRole); пустой список — всем
+	Load  func(ctx context.Context, state string) (pagedList, error)
+}
+
+// pageSources — источники постраничного вывода по виду списка.
+var pageSources map[string]pageSource
+
+func init() {
+	pageSources = map[string]pageSource{
+		"profiles": {Roles: rolesProfiles, Load: loadProfilesPage},
+		"stat":     {Load: loadStatisticPage},
+		"log":      {Roles: rolesTreasury, Load: loadLogPage},
+		"items":    {Load: loadItemsPage},
+		"shop":     {Load: loadShopPage},
+	}
+}
+
+// textLen возвращает длину текста так, как её считает Telegram (в UTF-16).
+func textLen(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// splitMessage делит текст на части не длиннее limit по границам строк.
+// Строка длиннее limit делится по символам.
+func splitMessage(text string, limit int) []string {
+	if textLen(text) <= limit {
+		return []string{text}
+	}
+	var parts []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+		}
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for textLen(line) > limit {
+			flush()
+			runes := []rune(line)
+			n := 0
+			for size := 0; n < len(runes); n++ {
+				size += len(utf16.Encode(runes[n : n+1]))
+				if size > limit {
+					break
+				}
+			}
+			parts = append(parts, string(runes[:n]))
+			line = string(runes[n:])
+		}
+		if textLen(current.String())+textLen(line) > limit {
+			flush()
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return parts
+}
+
+// sendLong отправляет текст одним или несколькими сообщениями, не превышая ограничение Telegram.
+func sendLong(bot Messenger, chatID int64, text string) {
+	for _, part := range splitMessage(text, messageLimit) {
+		bot.Send(tgbotapi.NewMessage(chatID, part))
+	}
+}
+
+// paginate раскладывает строки списка по страницам: не больше pageLines строк
+// и не длиннее messageLimit вместе с заголовком, подвалом и номером страницы.
+// Возвращает границы страниц — индексы первой строки и следующей за последней.
+func paginate(list pagedList) [][2]int {
+	// Запас под строку "Страница N из M".
+	budget := messageLimit - textLen(list.Header) - textLen(list.Footer) - 40
+	var pages [][2]int
+	start, size := 0, 0
+	for i, line := range list.Lines {
+		n := textLen(line) + 1
+		if i > start && (i-start == pageLines || size+n > budget) {
+			pages = append(pages, [2]int{start, i})
+			start, size = i, 0
+		}
+		size += n
+	}
+	if len(list.Lines) > start {
+		pages = append(pages, [2]int{start, len(list.Lines)})
+	}
+	return pages
+}
+
+// pageWithButton возвращает номер страницы, на которой находится кнопка с данными data (0, если её нет).
+func pageWithButton(list pagedList, data string) int {
+	for i, button := range list.Buttons {
+		if button.CallbackData == nil || *button.CallbackData != data {
+			continue
+		}
+		for page, bounds := range paginate(list) {
+			if i >= bounds[0] && i < bounds[1] {
+				return page
+			}
+		}
+	}
+	return 0
+}
+
+// pageData формирует данные кнопки перехода на страницу: page:<вид>:<страница>:<состояние>.
+func pageData(kind string, page int, state string) string {
+	return "page:" + kind + ":" + strconv.Itoa(page) + ":" + state
+}
+
+// renderPage формирует текст страницы, кнопки её строк и кнопки ◀ ▶ (nil, если кнопок нет).
+func renderPage(kind, state string, list pagedList, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	pages := paginate(list)
+	if len(pages) == 0 {
+		return list.Empty, nil
+	}
+	if page < 0 {
+		page = 0
+	}
+	if page >= len(pages) {
+		page = len(pages) - 1
+	}
+
+	var text strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	text.WriteString(list.Header)
+	for i := pages[page][0]; i < pages[page][1]; i++ {
+		text.WriteString(list.Lines[i] + "\n")
+		if i < len(list.Buttons) {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(list.Buttons[i]))
+		}
+	}
+	text.WriteString(list.Footer)
+	if len(pages) > 1 {
+		text.WriteString(fmt.Sprintf("\nСтраница %d из %d", page+1, len(pages)))
+		var row []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀", pageData(kind, page-1, state)))
+		}
+		if page < len(pages)-1 {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("▶", pageData(kind, page+1, state)))
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return text.String(), nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return text.String(), &keyboard
+}
+
+// sendPaged отправляет первую страницу списка. Если состояние не помещается в данные кнопки,
+// список отправляется целиком несколькими сообщениями.
+func sendPaged(bot Messenger, chatID int64, kind, state string, list pagedList) {
+	if len(pageData(kind, 999, state)) > callbackDataLimit {
+		if len(list.Lines) == 0 {
+			bot.Send(tgbotapi.NewMessage(chatID, list.Empty))
+			return
+		}
+		sendLong(bot, chatID, list.Header+strings.Join(list.Lines, "\n")+"\n"+list.Footer)
+		return
+	}
+	text, keyboard := renderPage(kind, state, list, 0)
+	msg := tgbotapi.NewMessage(chatID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	bot.Send(msg)
+}
+
+// HandlePageCallback показывает другую страницу списка, редактируя сообщение с кнопками.
+// Формат данных: page:<вид>:<страница>:<состояние>.
+func HandlePageCallback(bot Messenger, cq *tgbotapi.CallbackQuery) {
+	parts := strings.SplitN(cq.Data, ":", 4)
+	if len(parts) != 4 {
+		return
+	}
+	source, ok := pageSources[parts[1]]
+	page, err := strconv.Atoi(parts[2])
+	if !ok || err != nil {
+		return
+	}
+	if !hasRole(cq.From.ID, source.Roles) {
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "У вас нет прав для выполнения этой команды."))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := source.Load(ctx, parts[3])
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Ошибка при получении данных."))
+		return
+	}
+	text, keyboard := renderPage(parts[1], parts[3], list, page)
+	edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, text)
+	edit.ReplyMarkup = keyboard
+	bot.Send(edit)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestTextLen(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"Джек", 4}, // кириллица — по одной единице, хотя в UTF-8 по два байта
+		{"😀", 2},    // символ вне BMP — суррогатная пара
+		{"🏴‍☠️", 5}, // пиратский флаг: флаг, ZWJ, череп и селектор варианта
+		{"Ром 🍺\n", 7},
+	}
+	for _, tt := range tests {
+		if got := textLen(tt.in); got != tt.want {
+			t.Errorf("textLen(%q) = %d, ожидалось %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// checkParts проверяет, что части не длиннее limit и не разрывают символы.
+func checkParts(t *testing.T, parts []string, limit int) {
+	t.Helper()
+	for i, part := range parts {
+		if n := textLen(part); n > limit {
+			t.Errorf("часть %d длиной %d больше %d", i, n, limit)
+		}
+		if !utf8.ValidString(part) || strings.ContainsRune(part, utf8.RuneError) {
+			t.Errorf("часть %d содержит разорванный символ", i)
+		}
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	cyrillicLine := strings.Repeat("ж", 99) // со знаком перевода строки — 100 единиц
+	tests := []struct {
+		name string
+		text string
+		want []int // длины частей в единицах UTF-16
+	}{
+		{"ровно по пределу", strings.Repeat("я", messageLimit), []int{messageLimit}},
+		{"эмодзи ровно по пределу", strings.Repeat("😀", messageLimit/2), []int{messageLimit}},
+		{"на единицу больше предела", strings.Repeat("я", messageLimit+1), []int{messageLimit, 1}},
+		{"эмодзи не делится на границе", strings.Repeat("я", messageLimit-1) + "😀", []int{messageLimit - 1, 2}},
+		{"длинная строка эмодзи", strings.Repeat("😀", 2100), []int{messageLimit, 200}},
+		{
+			// В часть помещается ровно 40 строк по 100 единиц; завершающий перевод строки части отбрасывается.
+			"строки на кириллице",
+			strings.Repeat(cyrillicLine+"\n", 100),
+			[]int{3999, 3999, 1999},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitMessage(tt.text, messageLimit)
+			checkParts(t, parts, messageLimit)
+			var got []int
+			for _, part := range parts {
+				got = append(got, textLen(part))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("длины частей %v, ожидались %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("длины частей %v, ожидались %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageKeepsLines(t *testing.T) {
+	var lines []string
+	for i := 0; i < 300; i++ {
+		lines = append(lines, "🏴‍☠️ Капитан Джек — "+strings.Repeat("ром", i%7))
+	}
+	text := strings.Join(lines, "\n")
+	parts := splitMessage(text, messageLimit)
+	checkParts(t, parts, messageLimit)
+	if len(parts) < 2 {
+		t.Fatalf("частей %d, текст длиной %d должен быть разделён", len(parts), textLen(text))
+	}
+	// Текст делится только по границам строк: части склеиваются обратно в исходный текст.
+	if got := strings.Join(parts, "\n"); got != text {
+		t.Error("после склейки частей текст отличается от исходного")
+	}
+}
+
+// pagerLines возвращает n строк длиной size единиц UTF-16, оканчивающихся эмодзи.
+func pagerLines(n, size int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = strings.Repeat("ж", size-2) + "😀"
+	}
+	return lines
+}
+
+func TestRenderPageSplitsByLength(t *testing.T) {
+	// Строки по 900 единиц: вместе с заголовком на страницу помещаются четыре.
+	list := pagedList{Header: "Список:\n", Lines: pagerLines(10, 900), Footer: "Всего: 10"}
+	tests := []struct {
+		page     int
+		wantPage string
+		wantLen  int // строк на странице
+		wantNav  []string
+	}{
+		{0, "Страница 1 из 3", 4, []string{"page:test:1:st"}},
+		{1, "Страница 2 из 3", 4, []string{"page:test:0:st", "page:test:2:st"}},
+		{2, "Страница 3 из 3", 2, []string{"page:test:1:st"}},
+		{7, "Страница 3 из 3", 2, []string{"page:test:1:st"}}, // за последней страницей — последняя
+		{-1, "Страница 1 из 3", 4, []string{"page:test:1:st"}},
+	}
+	for _, tt := range tests {
+		text, keyboard := renderPage("test", "st", list, tt.page)
+		if n := textLen(text); n > messageLimit {
+			t.Errorf("страница %d длиной %d больше %d", tt.page, n, messageLimit)
+		}
+		if !strings.HasPrefix(text, list.Header) || !strings.Contains(text, list.Footer+"\n"+tt.wantPage) {
+			t.Errorf("страница %d без заголовка, подвала или номера %q:\n%s", tt.page, tt.wantPage, text)
+		}
+		if got := strings.Count(text, "😀"); got != tt.wantLen {
+			t.Errorf("на странице %d строк: %d, ожидалось %d", tt.page, got, tt.wantLen)
+		}
+		if keyboard == nil || len(keyboard.InlineKeyboard) != 1 {
+			t.Fatalf("страница %d: ожидалась одна строка кнопок навигации", tt.page)
+		}
+		var nav []string
+		for _, button := range keyboard.InlineKeyboard[0] {
+			nav = append(nav, *button.CallbackData)
+		}
+		if strings.Join(nav, " ") != strings.Join(tt.wantNav, " ") {
+			t.Errorf("страница %d: кнопки %v, ожидались %v", tt.page, nav, tt.wantNav)
+		}
+	}
+}
+
+func TestRenderPageLimits(t *testing.T) {
+	t.Run("пустой список", func(t *testing.T) {
+		text, keyboard := renderPage("test", "st", pagedList{Header: "Список:\n", Empty: "Пусто."}, 0)
+		if text != "Пусто." || keyboard != nil {
+			t.Errorf("текст %q и клавиатура %v, ожидались «Пусто.» без кнопок", text, keyboard)
+		}
+	})
+	t.Run("не больше pageLines строк", func(t *testing.T) {
+		list := pagedList{Lines: pagerLines(pageLines+5, 10)}
+		if pages := paginate(list); len(pages) != 2 || pages[0] != [2]int{0, pageLines} || pages[1] != [2]int{pageLines, pageLines + 5} {
+			t.Errorf("границы страниц %v", pages)
+		}
+	})
+	t.Run("одна страница с кнопками строк", func(t *testing.T) {
+		list := pagedList{
+			Lines:   []string{"Сабля 🗡", "Карта 🗺"},
+			Buttons: []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("Сабля", "item:1"), tgbotapi.NewInlineKeyboardButtonData("Карта", "item:2")},
+		}
+		text, keyboard := renderPage("test", "st", list, 0)
+		if strings.Contains(text, "Страница") {
+			t.Errorf("у единственной страницы не нужен номер: %q", text)
+		}
+		if keyboard == nil || len(keyboard.InlineKeyboard) != 2 {
+			t.Fatalf("ожидались две строки кнопок, получено %v", keyboard)
+		}
+	})
+	t.Run("строка длиной почти в сообщение", func(t *testing.T) {
+		// Каждая строка занимает страницу целиком, и страница всё равно не длиннее предела.
+		list := pagedList{Header: "Список:\n", Lines: pagerLines(3, messageLimit-100), Footer: "Конец"}
+		for page := 0; page < 3; page++ {
+			text, _ := renderPage("test", "st", list, page)
+			if n := textLen(text); n > messageLimit {
+				t.Errorf("страница %d длиной %d больше %d", page, n, messageLimit)
+			}
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// photoUploadLimit — ограничение Telegram на размер фотографии, отправляемой ботом.
+const photoUploadLimit = 10 << 20
+
+// errNotImage возвращается, когда в сообщении нет ни фотографии, ни изображения, отправленного файлом.
+var errNotImage = errors.New("в сообщении нет изображения")
+
+// isImageDocument сообщает, является ли документ изображением (картинкой, отправленной «без сжатия»).
+func isImageDocument(doc *tgbotapi.Document) bool {
+	return doc != nil && strings.HasPrefix(doc.MimeType, "image/")
+}
+
+// messagePhoto возвращает FileID фотографии из сообщения. Изображение, отправленное файлом,
+// бот пересылает пользователю как фотографию: FileID документа нельзя показать как фото.
+func messagePhoto(bot Messenger, message *tgbotapi.Message) (string, error) {
+	if len(message.Photo) > 0 {
+		return message.Photo[len(message.Photo)-1].FileID, nil
+	}
+	if !isImageDocument(message.Document) {
+		return "", errNotImage
+	}
+	if message.Document.FileSize > photoUploadLimit {
+		return "", fmt.Errorf("изображение больше %d МБ", photoUploadLimit>>20)
+	}
+	data, err := downloadFile(bot, message.Document.FileID)
+	if err != nil {
+		return "", err
+	}
+	photo := tgbotapi.NewPhoto(message.Chat.ID, tgbotapi.FileBytes{Name: message.Document.FileName, Bytes: data})
+	photo.Caption = "Изображение принято как фотография."
+	sent, err := bot.Send(photo)
+	if err != nil {
+		return "", err
+	}
+	if len(sent.Photo) == 0 {
+		return "", errors.New("Telegram не вернул фотографию")
+	}
+	return sent.Photo[len(sent.Photo)-1].FileID, nil
+}
+
+// downloadFile скачивает файл, ранее присланный боту, по его FileID.
+func downloadFile(bot Messenger, fileID string) ([]byte, error) {
+	resp, err := bot.Request(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+	var file tgbotapi.File
+	if err := json.Unmarshal(resp.Result, &file); err != nil {
+		return nil, err
+	}
+	if file.FilePath == "" {
+		return nil, errors.New("Telegram не вернул путь к файлу")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	// Текст ошибок ниже содержит адрес файла с токеном бота, поэтому он не передаётся дальше.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.Link(botConfig.Token), nil)
+	if err != nil {
+		return nil, errors.New("не удалось скачать файл")
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.New("не удалось скачать файл")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("скачивание файла: %s", res.Status)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, photoUploadLimit+1))
+}
@@ -1,92 +1,205 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-type RegistrationSession struct {
-	Step int
-	Data models.UserProfile
+// Ограничения на поля анкеты при регистрации.
+const (
+	nameMaxLen = 64
+	raceMaxLen = 64
+	ageMin     = 1
+	ageMax     = 1000
+	heightMin  = 30 // см
+	heightMax  = 300
+	weightMin  = 5 // кг
+	weightMax  = 500
+)
+
+// registrationSteps — стандартные вопросы регистрации. Ключи ответов совпадают с полями команды "изменить"
+// (см. profileFieldUpdate). Фотография запрашивается последней, после дополнительных полей.
+var registrationSteps = []conversationStep{
+	{Key: "name", Prompt: "Введите имя и/или псевдоним:", Validate: validateName},
+	{Key: "race", Prompt: "Введите расу:", Validate: validateRace},
+	{Key: "age", Prompt: "Введите возраст (число лет):", Validate: validateAge},
+	{Key: "height_weight", Prompt: "Введите рост в сантиметрах и вес в килограммах (например: 173.6 см\\70 кг):", Validate: validateHeightWeight},
+	{Key: "gender", Prompt: "Введите пол:", Validate: validateGender},
+	{Key: "photo_file_id", Prompt: "Отправьте фотографию персонажа:", Input: inputPhoto},
 }
 
-// Глобальное хранилище сеансов регистрации.
-var registrationSessions = make(map[int64]*RegistrationSession)
+// registrationFlow — диалог регистрации анкеты: стандартные вопросы и дополнительные поля,
+// которые игрок заполняет сам.
+var registrationFlow = &conversationFlow{
+	Build:     registrationBuild,
+	Finish:    finishRegistration,
+	Cancelled: "Регистрация отменена.",
+}
 
-// StartRegistration начинает процесс регистрации, запрашивая имя/псевдоним.
-func StartRegistration(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	registrationSessions[message.From.ID] = &RegistrationSession{
-		Step: 1,
-		Data: models.UserProfile{
-			TelegramID: message.From.ID,
-			Username:   strings.ToLower(message.From.UserName),
-			Rank:       "Ис",
-			Team:       "Наемник",
-			Oblomki:    0,
-			Piastry:    0,
-			Inventory:  "Пусто",
-		},
-	}
-	reply := "Введите имя и/или псевдоним:"
-	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
+// registrationBuild добавляет к стандартным вопросам регистрации открытые дополнительные поля анкеты.
+func registrationBuild(ctx context.Context) []conversationStep {
+	last := len(registrationSteps) - 1
+	steps := append([]conversationStep{}, registrationSteps[:last]...)
+	for _, field := range listCustomFields(ctx) {
+		if field.Editable {
+			steps = append(steps, fieldStep(field))
+		}
+	}
+	return append(steps, registrationSteps[last])
 }
 
-// ProcessRegistrationStep обрабатывает шаги регистрации.
-func ProcessRegistrationStep(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	session, exists := registrationSessions[message.From.ID]
-	if !exists {
-		return
+// validateName проверяет длину имени.
+func validateName(value string) (string, error) {
+	if utf8.RuneCountInString(value) > nameMaxLen {
+		return "", fmt.Errorf("Имя слишком длинное: не больше %d символов.", nameMaxLen)
 	}
-	// Если на шаге 6 ожидается фотография
-	if len(message.Photo) > 0 && session.Step == 6 {
-		photo := message.Photo[len(message.Photo)-1]
-		session.Data.PhotoFileID = photo.FileID
-		SaveUserProfile(session.Data)
-		reply := "Добро пожаловать на борт!"
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
-		delete(registrationSessions, message.From.ID)
-		return
+	return value, nil
+}
+
+// validateRace проверяет длину названия расы.
+func validateRace(value string) (string, error) {
+	if utf8.RuneCountInString(value) > raceMaxLen {
+		return "", fmt.Errorf("Название расы слишком длинное: не больше %d символов.", raceMaxLen)
 	}
-	switch session.Step {
-	case 1:
-		session.Data.Name = strings.TrimSpace(message.Text)
-		// Если регистрируется постоянный админ, добавляем эмодзи.
-		if strings.EqualFold(message.From.UserName, "My_Beautifu1_Madness") {
-			adminEmoji := "🏴‍☠️"
-			if !strings.Contains(session.Data.Name, adminEmoji) {
-				session.Data.Name = session.Data.Name + " " + adminEmoji
-			}
-			session.Data.IsAdmin = true
+	return value, nil
+}
+
+// validateAge принимает возраст целым числом в допустимых пределах.
+func validateAge(value string) (string, error) {
+	age, ok := models.ParseAge(value)
+	if !ok {
+		return "", errors.New("Возраст нужно указать целым числом, например: 25.")
+	}
+	if age < ageMin || age > ageMax {
+		return "", fmt.Errorf("Возраст должен быть от %d до %d.", ageMin, ageMax)
+	}
+	return fmt.Sprint(age), nil
+}
+
+// validateHeightWeight разбирает рост и вес и приводит их к виду "173.6 см, 70 кг".
+func validateHeightWeight(value string) (string, error) {
+	height, weight, ok := models.ParseHeightWeight(value)
+	if !ok {
+		return "", errors.New("Не удалось разобрать рост и вес. Укажите два числа: рост в сантиметрах и вес в килограммах, например: 180\\75.")
+	}
+	if height < heightMin || height > heightMax {
+		return "", fmt.Errorf("Рост должен быть от %d до %d см.", heightMin, heightMax)
+	}
+	if weight < weightMin || weight > weightMax {
+		return "", fmt.Errorf("Вес должен быть от %d до %d кг.", weightMin, weightMax)
+	}
+	return models.FormatHeightWeight(height, weight), nil
+}
+
+// validateGender принимает пол из списка в конфигурации (без учёта регистра). Если список пуст, подходит любой.
+func validateGender(value string) (string, error) {
+	if botConfig == nil || len(botConfig.Genders) == 0 {
+		return value, nil
+	}
+	for _, gender := range botConfig.Genders {
+		if strings.EqualFold(gender, value) {
+			return gender, nil
+		}
+	}
+	return "", fmt.Errorf("Выберите пол из списка: %s.", strings.Join(botConfig.Genders, ", "))
+}
+
+// registrationValidator возвращает проверку поля анкеты field (имя bson-поля) из диалога регистрации или nil.
+func registrationValidator(field string) func(string) (string, error) {
+	for _, step := range registrationSteps {
+		if step.Key == field {
+			return step.Validate
 		}
-		session.Step++
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Введите расу:"))
-	case 2:
-		session.Data.Race = strings.TrimSpace(message.Text)
-		session.Step++
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Введите возраст:"))
-	case 3:
-		session.Data.Age = strings.TrimSpace(message.Text)
-		session.Step++
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Введите рост и вес (например: 173.6 см\\70 кг):"))
-	case 4:
-		session.Data.HeightWeight = strings.TrimSpace(message.Text)
-		session.Step++
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Введите пол:"))
-	case 5:
-		session.Data.Gender = strings.TrimSpace(message.Text)
-		session.Step++
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Отправьте фотографию персонажа:"))
-	default:
-		// Ничего не делаем для неизвестного шага.
 	}
+	return nil
 }
 
-// resetRegistrationSessions очищает все активные сеансы регистрации.
-func resetRegistrationSessions() {
-	for id := range registrationSessions {
-		delete(registrationSessions, id)
+// profileFieldUpdate переводит проверенное значение поля анкеты в значения bson-полей для SetProfileFields.
+// Возраст, рост и вес хранятся числами; прежняя запись в свободной форме при этом стирается.
+func profileFieldUpdate(field, value string) map[string]interface{} {
+	switch field {
+	case "age":
+		age, _ := models.ParseAge(value)
+		return map[string]interface{}{"age": age, "age_note": ""}
+	case "height_weight":
+		height, weight, _ := models.ParseHeightWeight(value)
+		return map[string]interface{}{"height_cm": height, "weight_kg": weight, "height_weight_note": ""}
+	}
+	return map[string]interface{}{field: value}
+}
+
+// StartRegistration начинает процесс регистрации, запрашивая имя/псевдоним.
+func StartRegistration(bot Messenger, message *tgbotapi.Message) {
+	startConversation(bot, message, "registration", nil)
+}
+
+// finishRegistration сохраняет анкету по ответам диалога регистрации.
+// При повторной регистрации меняются только поля анкеты из диалога: баланс, ранг, команда, роли,
+// инвентарь и поля, которые заполняет администрация, сохраняются.
+func finishRegistration(bot Messenger, message *tgbotapi.Message, values map[string]string) {
+	name := values["name"]
+	// Если регистрируется владелец бота или администратор, добавляем эмодзи.
+	if isAdmin(message.From.ID) && !strings.Contains(name, AdminEmoji) {
+		name = name + " " + AdminEmoji
+	}
+	// Ответы уже проверены, поэтому разбираются без ошибок.
+	age, _ := models.ParseAge(values["age"])
+	height, weight, _ := models.ParseHeightWeight(values["height_weight"])
+	answers := map[string]interface{}{
+		"username":           strings.ToLower(message.From.UserName),
+		"name":               name,
+		"race":               values["race"],
+		"age":                age,
+		"age_note":           "",
+		"height_cm":          height,
+		"weight_kg":          weight,
+		"height_weight_note": "",
+		"gender":             values["gender"],
+		"photo_file_id":      values["photo_file_id"],
+	}
+	custom := make(map[string]string)
+	for key, value := range values {
+		if id, ok := strings.CutPrefix(key, fieldStepPrefix); ok {
+			custom[id] = value
+			answers["fields."+id] = value
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := store.SetProfileFields(ctx, message.From.ID, answers)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = store.SaveProfile(ctx, models.UserProfile{
+			TelegramID:  message.From.ID,
+			Username:    strings.ToLower(message.From.UserName),
+			Name:        name,
+			Race:        values["race"],
+			Age:         age,
+			HeightCm:    height,
+			WeightKg:    weight,
+			Gender:      values["gender"],
+			PhotoFileID: values["photo_file_id"],
+			Rank:        "Ис",
+			Team:        "Наемник",
+			Oblomki:     0,
+			Piastry:     0,
+			Fields:      custom,
+		})
+	}
+	if err != nil {
+		log.Printf("Ошибка сохранения анкеты %d: %v", message.From.ID, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Не удалось сохранить анкету. Попробуйте зарегистрироваться позже."))
+		return
 	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Добро пожаловать на борт!"))
 }
@@ -0,0 +1,240 @@
+package handlers_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/config"
+	"telegram-bot-go/handlers"
+	"telegram-bot-go/handlers/handlerstest"
+	"telegram-bot-go/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// register проходит диалог регистрации от имени пользователя from.
+func register(t *testing.T, bot handlers.Messenger, from int64, name string) {
+	t.Helper()
+	handlers.HandleCommand(bot, privateMessage(from, "регистрация"))
+	for _, answer := range []string{name, "Эльф", "120", "180\\75", "женский"} {
+		handlers.HandleNonCommandMessage(bot, privateMessage(from, answer))
+	}
+	photo := privateMessage(from, "")
+	photo.Photo = []tgbotapi.PhotoSize{{FileID: "photo-" + name}}
+	handlers.HandleNonCommandMessage(bot, photo)
+}
+
+func TestRegistrationNewProfile(t *testing.T) {
+	store, bot := setup(t)
+	register(t, bot, guestID, "Анна")
+	checkLast(t, bot.Texts(), "Добро пожаловать на борт!")
+
+	profile, err := store.GetProfile(context.Background(), guestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "Анна" || profile.Age != 120 || profile.HeightCm != 180 || profile.WeightKg != 75 || profile.PhotoFileID != "photo-Анна" {
+		t.Errorf("анкета заполнена неверно: %+v", profile)
+	}
+	if profile.Rank != "Ис" || profile.Team != "Наемник" || profile.Oblomki != 0 || profile.Piastry != 0 {
+		t.Errorf("новая анкета получила ранг %q, команду %q и баланс %d/%d", profile.Rank, profile.Team, profile.Oblomki, profile.Piastry)
+	}
+}
+
+func TestRegistrationKeepsProgress(t *testing.T) {
+	store, bot := setup(t)
+	ctx := context.Background()
+	itemID := primitive.NewObjectID()
+	err := store.SetProfileFields(ctx, playerID, map[string]interface{}{
+		"rank":  "Капитан",
+		"team":  "Чёрная жемчужина",
+		"roles": []string{models.RoleTreasurer},
+		"items": []models.InventoryItem{{ItemID: itemID, Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	register(t, bot, playerID, "Джек Воробей")
+	checkLast(t, bot.Texts(), "Добро пожаловать на борт!")
+
+	profile, err := store.GetProfile(ctx, playerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "Джек Воробей" || profile.Race != "Эльф" || profile.Gender != "женский" {
+		t.Errorf("ответы анкеты не сохранены: %+v", profile)
+	}
+	if profile.Oblomki != 7 || profile.Piastry != 12 {
+		t.Errorf("баланс %d/%d, ожидался прежний 7/12", profile.Oblomki, profile.Piastry)
+	}
+	if profile.Rank != "Капитан" || profile.Team != "Чёрная жемчужина" {
+		t.Errorf("ранг %q и команда %q не сохранены", profile.Rank, profile.Team)
+	}
+	if !profile.HasRole(models.RoleTreasurer) || len(profile.Items) != 1 || profile.Items[0].Quantity != 2 {
+		t.Errorf("роли %v или инвентарь %+v не сохранены", profile.Roles, profile.Items)
+	}
+}
+
+// answer отправляет ответ в диалог и возвращает текст ответа бота.
+func answer(t *testing.T, bot *handlerstest.RecordingMessenger, message *tgbotapi.Message) string {
+	t.Helper()
+	bot.Reset()
+	handlers.HandleNonCommandMessage(bot, message)
+	texts := bot.Texts()
+	if len(texts) == 0 {
+		t.Fatalf("нет ответа на %q", message.Text)
+	}
+	return texts[len(texts)-1]
+}
+
+func TestRegistrationFieldsChangedMidway(t *testing.T) {
+	store, bot := setup(t)
+	ctx := context.Background()
+	ship := &models.ProfileField{Key: "корабль", Name: "Корабль", Type: models.FieldText, Editable: true}
+	if err := store.CreateField(ctx, ship); err != nil {
+		t.Fatal(err)
+	}
+	handlers.HandleCommand(bot, privateMessage(guestID, "регистрация"))
+	for _, text := range []string{"Анна", "Эльф", "120", "180\\75"} {
+		handlers.HandleNonCommandMessage(bot, privateMessage(guestID, text))
+	}
+	if got := answer(t, bot, privateMessage(guestID, "женский")); !strings.HasPrefix(got, "Введите «Корабль»:") {
+		t.Fatalf("после пола задан вопрос %q, ожидалось поле «Корабль»", got)
+	}
+
+	// Поле удалено, пока игрок на него отвечал: диалог продолжается с первого вопроса без ответа.
+	if err := store.DeleteField(ctx, ship.ID); err != nil {
+		t.Fatal(err)
+	}
+	got := answer(t, bot, privateMessage(guestID, "Жемчужина"))
+	if !strings.Contains(got, "больше не задаётся") || !strings.Contains(got, "Отправьте фотографию персонажа:") {
+		t.Fatalf("ответ %q, ожидался переход к фотографии", got)
+	}
+
+	// Новое поле перед фотографией не сдвигает текущий вопрос.
+	if err := store.CreateField(ctx, &models.ProfileField{Key: "флаг", Name: "Флаг", Type: models.FieldText, Editable: true}); err != nil {
+		t.Fatal(err)
+	}
+	photo := privateMessage(guestID, "")
+	photo.Photo = []tgbotapi.PhotoSize{{FileID: "photo-Анна"}}
+	if got := answer(t, bot, photo); got != "Добро пожаловать на борт!" {
+		t.Fatalf("ответ на фотографию %q, ожидалось завершение регистрации", got)
+	}
+	profile, err := store.GetProfile(ctx, guestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "Анна" || profile.PhotoFileID != "photo-Анна" {
+		t.Errorf("анкета заполнена неверно: %+v", profile)
+	}
+}
+
+// checkLast проверяет текст последнего отправленного сообщения.
+func checkLast(t *testing.T, texts []string, want string) {
+	t.Helper()
+	if len(texts) == 0 || texts[len(texts)-1] != want {
+		t.Fatalf("последнее сообщение %q, ожидалось %q", texts, want)
+	}
+}
+
+// registrationAnswers — верные ответы на стандартные вопросы регистрации перед фотографией.
+var registrationAnswers = []string{"Анна", "Эльф", "120", "180\\75", "женский"}
+
+func TestRegistrationRejectsInvalidAnswers(t *testing.T) {
+	tests := []struct {
+		name   string
+		step   int // номер вопроса в registrationAnswers; len — фотография
+		answer string
+		want   string // объяснение ошибки
+		prompt string // повторный вопрос
+	}{
+		{"длинное имя", 0, strings.Repeat("я", 65), "Имя слишком длинное: не больше 64 символов.", "Введите имя"},
+		{"длинная раса", 1, strings.Repeat("э", 65), "Название расы слишком длинное: не больше 64 символов.", "Введите расу:"},
+		{"возраст не числом", 2, "много", "Возраст нужно указать целым числом, например: 25.", "Введите возраст"},
+		{"возраст меньше допустимого", 2, "0", "Возраст должен быть от 1 до 1000.", "Введите возраст"},
+		{"возраст больше допустимого", 2, "1001 год", "Возраст должен быть от 1 до 1000.", "Введите возраст"},
+		{"рост и вес не разобраны", 3, "высокий", "Не удалось разобрать рост и вес. Укажите два числа: рост в сантиметрах и вес в килограммах, например: 180\\75.", "Введите рост"},
+		{"рост вне пределов", 3, "20\\75", "Рост должен быть от 30 до 300 см.", "Введите рост"},
+		{"вес вне пределов", 3, "180\\600", "Вес должен быть от 5 до 500 кг.", "Введите рост"},
+		{"пол не из списка", 4, "кракен", "Выберите пол из списка: мужской, женский.", "Введите пол:"},
+		{"текст вместо фотографии", 5, "вот фото", "Нужна фотография: отправьте изображение как фото или файлом.", "Отправьте фотографию персонажа:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, bot := setup(t)
+			handlers.InitHandlers(store, &config.Config{OwnerID: ownerID, Genders: []string{"мужской", "женский"}})
+			handlers.HandleCommand(bot, privateMessage(guestID, "регистрация"))
+			for _, text := range registrationAnswers[:tt.step] {
+				handlers.HandleNonCommandMessage(bot, privateMessage(guestID, text))
+			}
+			got := answer(t, bot, privateMessage(guestID, tt.answer))
+			if !strings.HasPrefix(got, tt.want+"\n") || !strings.Contains(got, tt.prompt) {
+				t.Fatalf("ответ %q, ожидались %q и повторный вопрос %q", got, tt.want, tt.prompt)
+			}
+			// Вопрос задаётся снова: верный ответ принимается на том же шаге.
+			if tt.step < len(registrationAnswers) {
+				if got := answer(t, bot, privateMessage(guestID, registrationAnswers[tt.step])); strings.Contains(got, tt.prompt) {
+					t.Errorf("после верного ответа снова задан вопрос: %q", got)
+				}
+			}
+		})
+	}
+}
+
+// roundTripFunc подменяет HTTP-транспорт при скачивании файлов.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// documentMessage формирует сообщение с файлом типа mimeType размером size байт.
+func documentMessage(from int64, mimeType string, size int) *tgbotapi.Message {
+	message := privateMessage(from, "")
+	message.Document = &tgbotapi.Document{FileID: "doc-1", FileName: "pirate.png", MimeType: mimeType, FileSize: size}
+	return message
+}
+
+func TestRegistrationPhotoAsDocument(t *testing.T) {
+	store, bot := setup(t)
+	bot.SetFile("doc-1", "documents/file_1.png")
+	transport := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if !strings.HasSuffix(req.URL.Path, "/documents/file_1.png") {
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("png"))}, nil
+	})
+
+	handlers.HandleCommand(bot, privateMessage(guestID, "регистрация"))
+	for _, text := range registrationAnswers {
+		handlers.HandleNonCommandMessage(bot, privateMessage(guestID, text))
+	}
+
+	// Файл не с изображением и слишком большое изображение не принимаются.
+	if got := answer(t, bot, documentMessage(guestID, "application/pdf", 100)); !strings.HasPrefix(got, "Нужна фотография") {
+		t.Errorf("ответ на PDF %q", got)
+	}
+	if got := answer(t, bot, documentMessage(guestID, "image/png", 11<<20)); !strings.HasPrefix(got, "Не удалось принять изображение") {
+		t.Errorf("ответ на большое изображение %q", got)
+	}
+
+	bot.Reset()
+	handlers.HandleNonCommandMessage(bot, documentMessage(guestID, "image/png", 100))
+	sent := bot.Sent()
+	if len(sent) != 2 || !sent[0].IsPhoto || sent[1].Text != "Добро пожаловать на борт!" {
+		t.Fatalf("отправлено %q, ожидались фотография и приветствие", texts(sent))
+	}
+	profile, err := store.GetProfile(context.Background(), guestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.PhotoFileID == "" || profile.PhotoFileID == "doc-1" {
+		t.Errorf("в анкете FileID %q, ожидалась пересланная фотография", profile.PhotoFileID)
+	}
+}
@@ -0,0 +1,560 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"telegram-bot-go/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ChatType определяет, в каком чате можно вызвать команду.
+type ChatType int
+
+const (
+	ChatAny     ChatType = iota // в любом чате
+	ChatPrivate                 // только в личных сообщениях с ботом
+	ChatGroup                   // только в группе
+)
+
+// CommandHandler обрабатывает команду; args содержит текст после названия команды.
+type CommandHandler func(bot Messenger, message *tgbotapi.Message, args string)
+
+// Command описывает одну команду бота. Из этих описаний строятся диспетчер,
+// справка по команде "помощь" и меню команд Telegram.
+type Command struct {
+	Aliases  []string // названия команды; первое выводится в справке
+	Menu     string   // латинское имя для меню Telegram; пустое — команда в меню не попадает
+	Args     string   // синтаксис аргументов для справки
+	NeedArgs bool     // команда вызывается только с аргументами
+	Roles    []string // роли, которым доступна команда (см. hasRole); пустой список — всем
+	Chat     ChatType
+	Help     string
+	Handler  CommandHandler
+}
+
+// commands — реестр всех команд бота.
+var commands []*Command
+
+func init() {
+	commands = []*Command{
+		// Команды для обычных пользователей.
+		{
+			Aliases: []string{"регистрация"},
+			Menu:    "register",
+			Chat:    ChatPrivate,
+			Help:    "начать регистрацию анкеты",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { StartRegistration(bot, message) },
+		},
+		{
+			Aliases: []string{"анкета"},
+			Menu:    "profile",
+			Help:    "показать свою анкету",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { showUserProfile(bot, message) },
+		},
+		{
+			Aliases: []string{"где ром"},
+			Help:    "прервать свою незавершённую регистрацию или другой диалог (администратор прерывает все)",
+			Handler: handleResetConversation,
+		},
+		{
+			Aliases: []string{"статистика"},
+			Menu:    "stats",
+			Help:    "показать статистику участников",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleStatistic(bot, message) },
+		},
+		{
+			Aliases: []string{"изменить"},
+			Args:    "[поле] [значение]",
+			Chat:    ChatPrivate,
+			Help:    "изменить указанное поле анкеты, в том числе дополнительное",
+			Handler: handleChangeCommand,
+		},
+		{
+			Aliases: []string{"поля"},
+			Help:    "показать дополнительные поля анкеты",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleListFields(bot, message) },
+		},
+		{
+			Aliases: []string{"добавить"},
+			Args:    "[обломки/пиастры] [количество]",
+			Chat:    ChatPrivate,
+			Help:    "подать заявку на пополнение ресурса (рассматривает администрация)",
+			Handler: func(bot Messenger, message *tgbotapi.Message, args string) {
+				parts := strings.Fields(args)
+				if len(parts) < 2 {
+					bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: добавить обломки 5"))
+					return
+				}
+				handleAdd(bot, message, parts[0], parts[1])
+			},
+		},
+		{
+			Aliases: []string{"мои заявки"},
+			Chat:    ChatPrivate,
+			Help:    "показать свои заявки на пополнение, ожидающие рассмотрения",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleMyGrants(bot, message) },
+		},
+		{
+			Aliases: []string{"потерять"},
+			Args:    "[обломки/пиастры] [количество]",
+			Help:    "списать указанное количество ресурса",
+			Handler: func(bot Messenger, message *tgbotapi.Message, args string) {
+				parts := strings.Fields(args)
+				if len(parts) < 2 {
+					bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: потерять обломки 5"))
+					return
+				}
+				handleShow(bot, message, parts[0], parts[1])
+			},
+		},
+		{
+			Aliases: []string{"передать"},
+			Args:    "[обломки/пиастры] (@username) [количество]",
+			Help:    "передать ресурс другому участнику",
+			Handler: func(bot Messenger, message *tgbotapi.Message, args string) {
+				parts := strings.Fields(args)
+				if len(parts) < 3 {
+					bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Пример: передать обломки @username 5"))
+					return
+				}
+				handleTransfer(bot, message, parts[0], parts[1], parts[2])
+			},
+		},
+		{
+			Aliases: []string{"инвентарь"},
+			Menu:    "inventory",
+			Help:    "показать свой инвентарь",
+			Handler: handleInventory,
+		},
+		{
+			Aliases: []string{"предметы"},
+			Menu:    "items",
+			Help:    "показать каталог предметов",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleItemCatalog(bot, message) },
+		},
+		{
+			Aliases: []string{"магазин"},
+			Menu:    "shop",
+			Help:    "показать товары магазина с кнопками покупки",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleShop(bot, message) },
+		},
+		{
+			Aliases:  []string{"купить"},
+			Args:     "(предмет)[, (количество)]",
+			NeedArgs: true,
+			Help:     "купить товар в магазине",
+			Handler:  handleBuy,
+		},
+		{
+			Aliases:  []string{"обмен"},
+			Args:     "@username (что отдаёте) на (что хотите получить)",
+			NeedArgs: true,
+			Help:     "предложить обмен предметами и валютой, например: обмен @username 2 Ром, 10 пиастры на Сабля",
+			Handler:  handleTrade,
+		},
+		{
+			Aliases:  []string{"использовать"},
+			Args:     "(предмет)[, (количество)]",
+			NeedArgs: true,
+			Help:     "использовать предметы из своего инвентаря",
+			Handler:  handleUseItem,
+		},
+		{
+			Aliases:  []string{"выбросить"},
+			Args:     "(предмет)[, (количество)]",
+			NeedArgs: true,
+			Help:     "выбросить предметы из своего инвентаря",
+			Handler:  handleDiscardItem,
+		},
+		{
+			Aliases: []string{"удалить анкету"},
+			Chat:    ChatPrivate,
+			Help:    "удалить свою анкету (требуется подтверждение)",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleDeleteProfile(bot, message) },
+		},
+		{
+			Aliases: []string{"помощь", "помоги", "я забыл", "забыл", "список команд", "что ты умеешь", "что ты делаешь"},
+			Menu:    "help",
+			Help:    "показать список команд",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleHelp(bot, message) },
+		},
+
+		// Команды для администрации.
+		{
+			Aliases: []string{"список анкет"},
+			Roles:   rolesProfiles,
+			Help:    "вывести краткий список анкет всех участников",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { listProfiles(bot, message) },
+		},
+		{
+			Aliases: []string{"полный список анкет"},
+			Roles:   rolesProfiles,
+			Help:    "вывести каждую анкету с подробностями и фотографией",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { fullListProfiles(bot, message) },
+		},
+		{
+			Aliases:  []string{"анкета"},
+			Args:     "(айди анкеты)",
+			NeedArgs: true,
+			Roles:    rolesProfiles,
+			Help:     "вывести анкету по заданному ID",
+			Handler: func(bot Messenger, message *tgbotapi.Message, args string) {
+				showProfileByID(bot, message, strings.Fields(args)[0])
+			},
+		},
+		{
+			Aliases:  []string{"инвентарь"},
+			Args:     "(@username или ID анкеты)",
+			NeedArgs: true,
+			Roles:    rolesProfiles,
+			Help:     "показать инвентарь игрока",
+			Handler:  handleInventory,
+		},
+		{
+			Aliases:  []string{"выдать предмет"},
+			Args:     "(@username или ID анкеты) (предмет)[, (количество)]",
+			NeedArgs: true,
+			Roles:    rolesItems,
+			Help:     "выдать игроку предметы из каталога",
+			Handler:  handleGiveItem,
+		},
+		{
+			Aliases:  []string{"изъять предмет"},
+			Args:     "(@username или ID анкеты) (предмет)[, (количество)]",
+			NeedArgs: true,
+			Roles:    rolesItems,
+			Help:     "изъять предметы у игрока",
+			Handler:  handleTakeItem,
+		},
+		{
+			Aliases:  []string{"создать предмет"},
+			Args:     "(название)[, (описание)]",
+			NeedArgs: true,
+			Roles:    rolesAdmin,
+			Help:     "добавить предмет в каталог",
+			Handler:  handleCreateItem,
+		},
+		{
+			Aliases:  []string{"описать предмет"},
+			Args:     "(название), (описание)",
+			NeedArgs: true,
+			Roles:    rolesAdmin,
+			Help:     "изменить описание предмета в каталоге",
+			Handler:  handleDescribeItem,
+		},
+		{
+			Aliases:  []string{"удалить предмет"},
+			Args:     "(название)",
+			NeedArgs: true,
+			Roles:    rolesAdmin,
+			Help:     "удалить предмет из каталога, если его нет ни у одного игрока",
+			Handler:  handleDeleteItem,
+		},
+		{
+			Aliases:  []string{"выставить"},
+			Args:     "(предмет), (цена) (обломки/пиастры)[, (запас)]",
+			NeedArgs: true,
+			Roles:    rolesTreasury,
+			Help:     "выставить предмет из каталога в магазин; без запаса количество не ограничено",
+			Handler:  handleListItem,
+		},
+		{
+			Aliases:  []string{"цена"},
+			Args:     "(предмет), (цена) (обломки/пиастры)",
+			NeedArgs: true,
+			Roles:    rolesTreasury,
+			Help:     "изменить цену товара",
+			Handler:  handleSetPrice,
+		},
+		{
+			Aliases:  []string{"запас"},
+			Args:     "(предмет), (количество или «без ограничений»)",
+			NeedArgs: true,
+			Roles:    rolesTreasury,
+			Help:     "изменить запас товара",
+			Handler:  handleSetStock,
+		},
+		{
+			Aliases:  []string{"снять с продажи"},
+			Args:     "(предмет)",
+			NeedArgs: true,
+			Roles:    rolesTreasury,
+			Help:     "убрать товар из магазина",
+			Handler:  handleDelistItem,
+		},
+		{
+			Aliases:  []string{"создать поле"},
+			Args:     "(название), (текст/длинный текст/число)[, обязательное][, закрытое]",
+			NeedArgs: true,
+			Roles:    rolesAdmin,
+			Help:     "добавить поле в анкету; закрытое поле заполняет только администрация",
+			Handler:  handleCreateField,
+		},
+		{
+			Aliases:  []string{"настроить поле"},
+			Args:     "(название), [обязательное/необязательное][, открытое/закрытое]",
+			NeedArgs: true,
+			Roles:    rolesAdmin,
+			Help:     "изменить обязательность поля анкеты и то, кто его заполняет",
+			Handler:  handleConfigureField,
+		},
+		{
+			Aliases:  []string{"удалить поле"},
+			Args:     "(название)",
+			NeedArgs: true,
+			Roles:    rolesAdmin,
+			Help:     "удалить поле анкеты вместе со значениями у всех игроков",
+			Handler:  handleDeleteField,
+		},
+		{
+			Aliases:  []string{"заполнить поле"},
+			Args:     "@username (название), (значение)",
+			NeedArgs: true,
+			Roles:    rolesProfiles,
+			Help:     "задать значение поля в анкете игрока; без значения поле очищается",
+			Handler:  handleFillField,
+		},
+		{
+			Aliases: []string{"датьадмин"},
+			Args:    "@username",
+			Roles:   rolesOwner,
+			Help:    "назначить пользователя администратором — то же, что дать роль @username админ",
+			Handler: handleGrantAdmin,
+		},
+		{
+			Aliases: []string{"дать роль"},
+			Args:    "@username (админ/гейм-мастер/казначей)",
+			Roles:   rolesAdmin,
+			Help:    "назначить роль; администраторов назначает только владелец",
+			Handler: handleGrantRole,
+		},
+		{
+			Aliases: []string{"снять роль"},
+			Args:    "@username (админ/гейм-мастер/казначей)",
+			Roles:   rolesAdmin,
+			Help:    "снять роль; администраторов снимает только владелец",
+			Handler: handleRevokeRole,
+		},
+		{
+			Aliases: []string{"роли"},
+			Roles:   rolesAdmin,
+			Help:    "показать пользователей с ролями",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleListRoles(bot, message) },
+		},
+		{
+			Aliases: []string{"аудит"},
+			Args:    "[от @username] [над @username/ID] [с ДД.ММ.ГГГГ] [по ДД.ММ.ГГГГ]",
+			Roles:   rolesAdmin,
+			Help:    "показать журнал действий администрации и ролей",
+			Handler: handleAudit,
+		},
+		{
+			Aliases: []string{"живой"},
+			Roles:   rolesAdmin,
+			Help:    "прервать все незавершённые диалоги, включая регистрацию",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) {
+				resetConversations()
+				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "сэр, да, сэр!"))
+			},
+		},
+		{
+			Aliases: []string{"чек лог"},
+			Args:    "[день/неделя/месяц] [@username/ID анкеты] [обломки/пиастры] [+/-] [с ДД.ММ.ГГГГ] [по ДД.ММ.ГГГГ] [csv]",
+			Roles:   rolesTreasury,
+			Help:    "вывести лог изменений ресурсов с итогами по игрокам; csv — прислать файлом",
+			Handler: handleCheckLogCommand,
+		},
+		{
+			Aliases: []string{"начатьивент", "начать ивент"},
+			Args:    "(имя), (число обломков), (число пиастр)[, (длительность: 90 или 1h30m)][, (начало: 18:30 или 20.10 18:30)]",
+			Roles:   rolesEvents,
+			Chat:    ChatGroup,
+			Help:    "начать ивент по добавлению валюты; с длительностью ивент завершится сам, с временем начала — будет объявлен в срок",
+			Handler: HandleCreateEvent,
+		},
+		{
+			Aliases:  []string{"завершитьивент", "завершить ивент"},
+			Args:     "(ID ивента)",
+			NeedArgs: true,
+			Roles:    rolesEvents,
+			Help:     "завершить ивент и подвести итоги (запланированный ивент отменяется)",
+			Handler:  handleCloseEvent,
+		},
+		{
+			Aliases: []string{"ивенты"},
+			Roles:   rolesEvents,
+			Help:    "показать активные и запланированные ивенты и их ID",
+			Handler: func(bot Messenger, message *tgbotapi.Message, _ string) { handleListEvents(bot, message) },
+		},
+		{
+			Aliases: []string{"участники ивента"},
+			Args:    "(ID ивента)",
+			Roles:   rolesEvents,
+			Help:    "показать, кто участвует в ивенте и кто пропускает (без ID — последний активный ивент)",
+			Handler: handleEventRoster,
+		},
+	}
+}
+
+// matchAlias проверяет, начинается ли команда words со слов алиаса,
+// и возвращает количество совпавших слов (0 — не совпадает).
+func matchAlias(words []string, alias string) int {
+	aliasWords := strings.Fields(alias)
+	if len(aliasWords) == 0 || len(words) < len(aliasWords) {
+		return 0
+	}
+	for i, w := range aliasWords {
+		if words[i] != w {
+			return 0
+		}
+	}
+	return len(aliasWords)
+}
+
+// findCommand ищет команду по тексту сообщения (без слэша) и возвращает её вместе с аргументами.
+// Среди совпадений выбирается самый длинный алиас; если у команды с таким алиасом есть
+// варианты с аргументами и без, выбор делается по наличию аргументов.
+func findCommand(text string) (*Command, string) {
+	fields := strings.Fields(text)
+	words := strings.Fields(strings.ToLower(text))
+
+	var candidates []*Command
+	best := 0
+	for _, cmd := range commands {
+		names := cmd.Aliases
+		if cmd.Menu != "" {
+			names = append(names[:len(names):len(names)], cmd.Menu)
+		}
+		for _, alias := range names {
+			n := matchAlias(words, alias)
+			if n == 0 || n < best {
+				continue
+			}
+			if n > best {
+				best = n
+				candidates = candidates[:0]
+			}
+			candidates = append(candidates, cmd)
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ""
+	}
+
+	args := strings.Join(fields[best:], " ")
+	hasArgs := args != ""
+	for _, cmd := range candidates {
+		if cmd.NeedArgs == hasArgs {
+			return cmd, args
+		}
+	}
+	return candidates[0], args
+}
+
+// chatAllowed проверяет, подходит ли чат для вызова команды.
+func chatAllowed(cmd *Command, chat *tgbotapi.Chat) bool {
+	switch cmd.Chat {
+	case ChatPrivate:
+		return chat.IsPrivate()
+	case ChatGroup:
+		return chat.IsGroup() || chat.IsSuperGroup()
+	}
+	return true
+}
+
+// HandleCommand находит команду в реестре, проверяет права и тип чата и вызывает обработчик.
+// Текст сообщения передаётся без ведущего слэша.
+func HandleCommand(bot Messenger, message *tgbotapi.Message) {
+	cmd, args := findCommand(message.Text)
+	if cmd == nil {
+		return
+	}
+	if !chatAllowed(cmd, message.Chat) {
+		switch cmd.Chat {
+		case ChatPrivate:
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Команда «%s» доступна только в личных сообщениях с ботом — напишите её мне в личку.", cmd.Aliases[0])))
+		case ChatGroup:
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Команда «%s» доступна только в групповом чате.", cmd.Aliases[0])))
+		}
+		return
+	}
+	// Действия, требующие ролей, вместе с ответом бота записываются в журнал действий.
+	if len(cmd.Roles) > 0 {
+		recorder := newAuditRecorder(bot)
+		defer recordAudit(message.From, cmd.Aliases[0], args, recorder)
+		bot = recorder
+	}
+	if !hasRole(message.From.ID, cmd.Roles) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "У вас нет прав для выполнения этой команды."))
+		return
+	}
+	if cmd.NeedArgs && args == "" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Неверный формат. Используйте: %s", commandUsage(cmd))))
+		return
+	}
+	cmd.Handler(bot, message, args)
+}
+
+// commandUsage возвращает основное название команды вместе с синтаксисом аргументов.
+func commandUsage(cmd *Command) string {
+	if cmd.Args == "" {
+		return cmd.Aliases[0]
+	}
+	return cmd.Aliases[0] + " " + cmd.Args
+}
+
+// helpText формирует справку по всем командам из реестра.
+func helpText() string {
+	var players, admins strings.Builder
+	for _, cmd := range commands {
+		if len(cmd.Roles) == 0 {
+			players.WriteString(fmt.Sprintf("• %s – %s%s\n", commandUsage(cmd), cmd.Help, chatNote(cmd.Chat)))
+			continue
+		}
+		admins.WriteString(fmt.Sprintf("• %s – %s%s (%s)\n", commandUsage(cmd), cmd.Help, chatNote(cmd.Chat), rolesText(cmd.Roles)))
+	}
+	return "Команды для обычных пользователей:\n \n" + players.String() +
+		"\nКоманды для администрации и ролей:\n" + admins.String()
+}
+
+// chatNote возвращает пометку для справки о том, где доступна команда.
+func chatNote(chat ChatType) string {
+	switch chat {
+	case ChatPrivate:
+		return " (в личных сообщениях)"
+	case ChatGroup:
+		return " (в групповом чате)"
+	}
+	return ""
+}
+
+// rolesText перечисляет роли, которым доступна команда, с учётом владельца и администрации.
+func rolesText(roles []string) string {
+	labels := []string{models.RoleLabel(models.RoleOwner)}
+	if len(roles) == 1 && roles[0] == models.RoleOwner {
+		return labels[0]
+	}
+	labels = append(labels, models.RoleLabel(models.RoleAdmin))
+	for _, role := range roles {
+		if role != models.RoleOwner && role != models.RoleAdmin {
+			labels = append(labels, models.RoleLabel(role))
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// CommandMenu возвращает команды для меню Telegram (setMyCommands).
+// В меню попадают только общедоступные команды с заданным латинским именем.
+func CommandMenu() []tgbotapi.BotCommand {
+	var menu []tgbotapi.BotCommand
+	for _, cmd := range commands {
+		if cmd.Menu == "" || len(cmd.Roles) > 0 {
+			continue
+		}
+		menu = append(menu, tgbotapi.BotCommand{Command: cmd.Menu, Description: cmd.Help})
+	}
+	return menu
+}
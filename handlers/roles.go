@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Роли, которым доступны группы команд. Владелец и администраторы проходят любую проверку,
+// кроме команд владельца, поэтому отдельно их указывать не нужно.
+var (
+	rolesAdmin    = []string{models.RoleAdmin}
+	rolesOwner    = []string{models.RoleOwner}
+	rolesEvents   = []string{models.RoleGameMaster}
+	rolesTreasury = []string{models.RoleTreasurer}
+	rolesProfiles = []string{models.RoleGameMaster, models.RoleTreasurer}
+	rolesItems    = []string{models.RoleGameMaster, models.RoleTreasurer}
+)
+
+// assignableRoles — роли, которые можно назначить командой, в порядке вывода.
+var assignableRoles = []string{models.RoleAdmin, models.RoleGameMaster, models.RoleTreasurer}
+
+// canManageRole проверяет, может ли пользователь назначать и снимать роль:
+// администраторов назначает только владелец, остальные роли — администрация.
+func canManageRole(telegramID int64, role string) bool {
+	switch role {
+	case models.RoleAdmin:
+		return IsOwner(telegramID)
+	case models.RoleGameMaster, models.RoleTreasurer:
+		return isAdmin(telegramID)
+	}
+	return false
+}
+
+// parseRoleArgs разбирает аргументы "@username (роль)".
+func parseRoleArgs(args string) (username, role string, err error) {
+	parts := strings.Fields(args)
+	if len(parts) < 2 {
+		return "", "", errors.New("неверный формат")
+	}
+	role, ok := models.ParseRole(strings.Join(parts[1:], " "))
+	if !ok {
+		return "", "", errors.New("неизвестная роль")
+	}
+	return strings.ToLower(strings.TrimPrefix(parts[0], "@")), role, nil
+}
+
+// changeRole назначает (grant = true) или снимает роль с пользователя и сообщает о результате.
+func changeRole(bot Messenger, message *tgbotapi.Message, username, role string, grant bool) {
+	if role == models.RoleOwner || role == models.RolePlayer {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			"Эту роль нельзя назначить или снять: владелец задаётся в конфигурации, а роль игрока есть у всех."))
+		return
+	}
+	if !canManageRole(message.From.ID, role) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "У вас нет прав для управления этой ролью."))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	profile, err := store.GetProfileByUsername(ctx, username)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Пользователь не найден или не зарегистрирован."))
+		return
+	}
+	if grant {
+		err = store.AddRole(ctx, profile.TelegramID, role)
+	} else {
+		err = store.RemoveRole(ctx, profile.TelegramID, role)
+	}
+	if err != nil {
+		log.Printf("Ошибка изменения роли %s у %d: %v", role, profile.TelegramID, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении роли."))
+		return
+	}
+
+	// Администраторы отмечаются эмодзи в имени.
+	if role == models.RoleAdmin {
+		name := strings.TrimSpace(strings.ReplaceAll(profile.Name, AdminEmoji, ""))
+		if grant {
+			name = name + " " + AdminEmoji
+		}
+		if err := store.SetProfileFields(ctx, profile.TelegramID, map[string]interface{}{"name": name}); err != nil {
+			log.Printf("Ошибка обновления имени администратора %d: %v", profile.TelegramID, err)
+		}
+	}
+
+	verb := "назначена роль"
+	if !grant {
+		verb = "снята роль"
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Пользователю @%s %s: %s.", username, verb, models.RoleLabel(role))))
+}
+
+// handleGrantRole обрабатывает команду "дать роль @username (роль)".
+func handleGrantRole(bot Messenger, message *tgbotapi.Message, args string) {
+	username, role, err := parseRoleArgs(args)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат команды. Пример: дать роль @username казначей\n"+roleNamesHint()))
+		return
+	}
+	changeRole(bot, message, username, role, true)
+}
+
+// handleRevokeRole обрабатывает команду "снять роль @username (роль)".
+func handleRevokeRole(bot Messenger, message *tgbotapi.Message, args string) {
+	username, role, err := parseRoleArgs(args)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат команды. Пример: снять роль @username казначей\n"+roleNamesHint()))
+		return
+	}
+	changeRole(bot, message, username, role, false)
+}
+
+// handleGrantAdmin обрабатывает команду "датьадмин @username" — сокращение для "дать роль @username админ".
+func handleGrantAdmin(bot Messenger, message *tgbotapi.Message, args string) {
+	parts := strings.Fields(args)
+	if len(parts) < 1 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат команды. Пример: датьадмин @username"))
+		return
+	}
+	changeRole(bot, message, strings.ToLower(strings.TrimPrefix(parts[0], "@")), models.RoleAdmin, true)
+}
+
+// handleListRoles выводит владельца и всех пользователей с назначенными ролями.
+func handleListRoles(bot Messenger, message *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	profiles, err := store.ListProfiles(ctx, storage.SortByName)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении списка ролей."))
+		return
+	}
+
+	var result strings.Builder
+	result.WriteString("Роли:\n")
+	owner := fmt.Sprintf("ID %d", botConfig.OwnerID)
+	for _, profile := range profiles {
+		if profile.TelegramID == botConfig.OwnerID {
+			owner = fmt.Sprintf("%s (@%s)", profile.Name, profile.Username)
+		}
+	}
+	result.WriteString(fmt.Sprintf("• %s — %s\n", owner, models.RoleLabel(models.RoleOwner)))
+	for _, profile := range profiles {
+		if len(profile.Roles) == 0 {
+			continue
+		}
+		labels := make([]string, 0, len(profile.Roles))
+		roles := append([]string(nil), profile.Roles...)
+		sort.Strings(roles)
+		for _, role := range roles {
+			labels = append(labels, models.RoleLabel(role))
+		}
+		result.WriteString(fmt.Sprintf("• %s (@%s) — %s\n", profile.Name, profile.Username, strings.Join(labels, ", ")))
+	}
+	result.WriteString("\nОстальные пользователи — " + models.RoleLabel(models.RolePlayer) + ".")
+	sendLong(bot, message.Chat.ID, result.String())
+}
+
+// roleNamesHint возвращает подсказку с ролями, которые можно назначить.
+func roleNamesHint() string {
+	labels := make([]string, 0, len(assignableRoles))
+	for _, role := range assignableRoles {
+		labels = append(labels, models.RoleLabel(role))
+	}
+	return "Роли: " + strings.Join(labels, ", ") + "."
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// shopBuyLimit — сколько штук товара можно купить за один раз.
+const shopBuyLimit = 100
+
+// shopCallbackData формирует данные кнопки покупки одной штуки товара.
+func shopCallbackData(listingID primitive.ObjectID) string {
+	return "shop:" + listingID.Hex()
+}
+
+// listingText формирует описание цены и запаса товара: "10 пиастры, осталось 3".
+func listingText(listing *models.ShopListing) string {
+	text := fmt.Sprintf("%d %s", listing.Price, models.ResourceLabel(listing.Resource))
+	switch {
+	case listing.Stock == 0:
+		text += ", нет в наличии"
+	case listing.Stock != models.StockUnlimited:
+		text += fmt.Sprintf(", осталось %d", listing.Stock)
+	}
+	return text
+}
+
+// handleShop выводит товары магазина с кнопками покупки.
+func handleShop(bot Messenger, message *tgbotapi.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := loadShopPage(ctx, "")
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при получении товаров магазина."))
+		return
+	}
+	sendPaged(bot, message.Chat.ID, "shop", "", list)
+}
+
+// loadShopPage формирует список товаров магазина для постраничного вывода.
+func loadShopPage(ctx context.Context, _ string) (pagedList, error) {
+	listings, err := store.ListListings(ctx)
+	if err != nil {
+		return pagedList{}, err
+	}
+	names := itemNames(ctx)
+	list := pagedList{
+		Header: "Магазин. Кнопка покупает одну штуку, несколько — командой: купить (предмет), (количество)\n\n",
+		Empty:  "В магазине пока ничего нет.",
+	}
+	for i := range listings {
+		listing := &listings[i]
+		name := itemName(names, listing.ItemID)
+		list.Lines = append(list.Lines, fmt.Sprintf("• %s — %s", name, listingText(listing)))
+		list.Buttons = append(list.Buttons, tgbotapi.NewInlineKeyboardButtonData("Купить: "+name, shopCallbackData(listing.ID)))
+	}
+	return list, nil
+}
+
+// buyListing проводит покупку, записывает её в лог и возвращает ответ для покупателя
+// и признак того, что покупка состоялась.
+func buyListing(ctx context.Context, telegramID int64, listingID primitive.ObjectID, quantity int) (string, bool) {
+	buyer, listing, err := store.Buy(ctx, listingID, telegramID, quantity)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		if _, err := store.GetProfile(ctx, telegramID); err != nil {
+			return "Анкета не найдена. Зарегистрируйтесь командой: регистрация", false
+		}
+		return "Этот товар больше не продаётся.", false
+	case errors.Is(err, storage.ErrOutOfStock):
+		return "Товар закончился.", false
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return "Недостаточно средств для покупки.", false
+	case err != nil:
+		log.Printf("Ошибка покупки товара %s пользователем %d: %v", listingID.Hex(), telegramID, err)
+		return "Ошибка при покупке. Баланс не изменён.", false
+	}
+
+	name := itemName(itemNames(ctx), listing.ItemID)
+	cost := listing.Price * quantity
+	label := models.ResourceLabel(listing.Resource)
+	AddLogEvent(*buyer, -cost, label)
+	AddLogEvent(*buyer, quantity, name)
+	return fmt.Sprintf("Куплено: %s ×%d за %d %s. Баланс: %d %s.", name, quantity, cost, label, buyer.Balance(listing.Resource), label), true
+}
+
+// handleBuy покупает товар командой. Формат: купить Название[, количество].
+func handleBuy(bot Messenger, message *tgbotapi.Message, args string) {
+	name, quantity, err := parseItemAmount(args)
+	if err != nil || quantity > shopBuyLimit {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("Неверный формат. Например: купить Ром, 2 (не больше %d штук за раз)", shopBuyLimit)))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	item, ok := findItem(ctx, bot, message.Chat.ID, name)
+	if !ok {
+		return
+	}
+	listing, err := store.GetListingByItem(ctx, item.ID)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Предмет «%s» не продаётся. Товары: магазин", item.Name)))
+		return
+	}
+	reply, _ := buyListing(ctx, message.From.ID, listing.ID, quantity)
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
+}
+
+// HandleShopCallback покупает одну штуку товара по кнопке, отвечает всплывающим уведомлением
+// и после покупки обновляет список товаров в сообщении. Формат данных: shop:<ID товара>.
+func HandleShopCallback(bot Messenger, cq *tgbotapi.CallbackQuery) {
+	listingID, err := primitive.ObjectIDFromHex(strings.TrimPrefix(cq.Data, "shop:"))
+	if err != nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Неизвестный товар."))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	reply, bought := buyListing(ctx, cq.From.ID, listingID, 1)
+	bot.Request(tgbotapi.NewCallback(cq.ID, reply))
+
+	if !bought || cq.Message == nil {
+		return
+	}
+	list, err := loadShopPage(ctx, "")
+	if err != nil {
+		return
+	}
+	text, keyboard := renderPage("shop", "", list, pageWithButton(list, cq.Data))
+	edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, text)
+	edit.ReplyMarkup = keyboard
+	bot.Send(edit)
+}
+
+// parsePrice разбирает цену вида "10 пиастры".
+func parsePrice(s string) (resource string, price int, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return "", 0, errors.New("укажите цену и валюту, например: 10 пиастры")
+	}
+	price, err = strconv.Atoi(fields[0])
+	if err != nil || price <= 0 {
+		return "", 0, errors.New("цена должна быть положительным числом")
+	}
+	resource, ok := models.ParseResource(fields[1])
+	if !ok {
+		return "", 0, errors.New("валюта — обломки или пиастры")
+	}
+	return resource, price, nil
+}
+
+// parseStock разбирает запас товара: число или "без ограничений".
+func parseStock(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "без ограничений" || s == "без ограничения" || s == "∞" {
+		return models.StockUnlimited, nil
+	}
+	stock, err := strconv.Atoi(s)
+	if err != nil || stock < 0 {
+		return 0, errors.New("запас — неотрицательное число или «без ограничений»")
+	}
+	return stock, nil
+}
+
+// handleListItem выставляет предмет в магазин.
+// Формат: выставить Название, цена валюта[, запас]. Без запаса количество не ограничено.
+func handleListItem(bot Messenger, message *tgbotapi.Message, args string) {
+	parts := strings.Split(args, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: выставить Ром, 10 пиастры, 5"))
+		return
+	}
+	resource, price, err := parsePrice(parts[1])
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	stock := models.StockUnlimited
+	if len(parts) == 3 {
+		if stock, err = parseStock(parts[2]); err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: "+err.Error()))
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item, ok := findItem(ctx, bot, message.Chat.ID, parts[0])
+	if !ok {
+		return
+	}
+	listing := &models.ShopListing{
+		ItemID:    item.ID,
+		Resource:  resource,
+		Price:     price,
+		Stock:     stock,
+		CreatedAt: time.Now(),
+		CreatedBy: message.From.ID,
+	}
+	err = store.AddListing(ctx, listing)
+	if errors.Is(err, storage.ErrAlreadyListed) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("Предмет «%s» уже продаётся. Изменить цену: цена, запас: запас", item.Name)))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при выставлении товара."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Предмет «%s» выставлен в магазин: %s.", item.Name, listingText(listing))))
+}
+
+// findListing ищет товар по названию предмета и сообщает, если предмет не продаётся.
+func findListing(ctx context.Context, bot Messenger, chatID int64, name string) (*models.Item, *models.ShopListing, bool) {
+	item, ok := findItem(ctx, bot, chatID, name)
+	if !ok {
+		return nil, nil, false
+	}
+	listing, err := store.GetListingByItem(ctx, item.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Предмет «%s» не продаётся.", item.Name)))
+		return nil, nil, false
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении товара."))
+		return nil, nil, false
+	}
+	return item, listing, true
+}
+
+// handleSetPrice меняет цену товара. Формат: цена Название, цена валюта.
+func handleSetPrice(bot Messenger, message *tgbotapi.Message, args string) {
+	name, priceText, ok := strings.Cut(args, ",")
+	resource, price, err := parsePrice(priceText)
+	if !ok || err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: цена Ром, 12 обломки"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item, listing, ok := findListing(ctx, bot, message.Chat.ID, name)
+	if !ok {
+		return
+	}
+	if err := store.SetListingPrice(ctx, listing.ID, resource, price); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении цены."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+		fmt.Sprintf("Цена предмета «%s»: %d %s.", item.Name, price, models.ResourceLabel(resource))))
+}
+
+// handleSetStock меняет запас товара. Формат: запас Название, количество (или "без ограничений").
+func handleSetStock(bot Messenger, message *tgbotapi.Message, args string) {
+	name, stockText, ok := strings.Cut(args, ",")
+	stock, err := parseStock(stockText)
+	if !ok || err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. Например: запас Ром, 5 или запас Ром, без ограничений"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item, listing, ok := findListing(ctx, bot, message.Chat.ID, name)
+	if !ok {
+		return
+	}
+	if err := store.SetListingStock(ctx, listing.ID, stock); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при изменении запаса."))
+		return
+	}
+	reply := fmt.Sprintf("Запас предмета «%s»: %d.", item.Name, stock)
+	if stock == models.StockUnlimited {
+		reply = fmt.Sprintf("Запас предмета «%s» не ограничен.", item.Name)
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reply))
+}
+
+// handleDelistItem снимает предмет с продажи. Формат: снять с продажи Название.
+func handleDelistItem(bot Messenger, message *tgbotapi.Message, args string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item, listing, ok := findListing(ctx, bot, message.Chat.ID, args)
+	if !ok {
+		return
+	}
+	if err := store.DeleteListing(ctx, listing.ID); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при снятии товара с продажи."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Предмет «%s» снят с продажи.", item.Name)))
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/models"
+	"telegram-bot-go/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// tradeOfferTimeout — сколько предложение обмена ждёт ответа, прежде чем истечь.
+const tradeOfferTimeout = 30 * time.Minute
+
+// tradeUsage — подсказка по формату команды "обмен".
+const tradeUsage = "Используйте: обмен @username (что отдаёте) на (что хотите получить). " +
+	"Например: обмен @username 2 Ром, 10 пиастры на Сабля, 5 обломки. Если одна из сторон ничего не отдаёт, укажите «ничего»."
+
+// tradeCallbackData формирует данные кнопки предложения обмена: trade:<действие>:<ID предложения>.
+func tradeCallbackData(action string, id primitive.ObjectID) string {
+	return "trade:" + action + ":" + id.Hex()
+}
+
+// parseTradeGoods разбирает одну сторону обмена: перечисление через запятую вида
+// "[количество] название", где название — предмет из каталога, обломки или пиастры.
+func parseTradeGoods(ctx context.Context, text string) (models.TradeGoods, error) {
+	var goods models.TradeGoods
+	text = strings.TrimSpace(text)
+	if strings.EqualFold(text, "ничего") {
+		return goods, nil
+	}
+	for _, part := range strings.Split(text, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			return goods, errors.New("пустой пункт в перечислении")
+		}
+		quantity := 1
+		if n, err := strconv.Atoi(fields[0]); err == nil {
+			if n <= 0 {
+				return goods, fmt.Errorf("неверное количество: %s", fields[0])
+			}
+			quantity, fields = n, fields[1:]
+		}
+		name := strings.Join(fields, " ")
+		if name == "" {
+			return goods, fmt.Errorf("не указано, что именно: %s", strings.TrimSpace(part))
+		}
+		if resource, ok := models.ParseResource(name); ok {
+			switch resource {
+			case models.ResourceOblomki:
+				goods.Oblomki += quantity
+			case models.ResourcePiastry:
+				goods.Piastry += quantity
+			}
+			continue
+		}
+		item, err := store.GetItemByKey(ctx, models.ItemKey(name))
+		if err != nil {
+			return goods, fmt.Errorf("предмет «%s» не найден в каталоге", name)
+		}
+		merged := false
+		for i := range goods.Items {
+			if goods.Items[i].ItemID == item.ID {
+				goods.Items[i].Quantity += quantity
+				merged = true
+			}
+		}
+		if !merged {
+			goods.Items = append(goods.Items, models.InventoryItem{ItemID: item.ID, Quantity: quantity})
+		}
+	}
+	return goods, nil
+}
+
+// goodsText формирует описание стороны обмена: "10 пиастры, Ром ×2".
+func goodsText(goods models.TradeGoods, names map[primitive.ObjectID]string) string {
+	var parts []string
+	if goods.Oblomki > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", goods.Oblomki, models.ResourceLabel(models.ResourceOblomki)))
+	}
+	if goods.Piastry > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", goods.Piastry, models.ResourceLabel(models.ResourcePiastry)))
+	}
+	for _, item := range goods.Items {
+		parts = append(parts, fmt.Sprintf("%s ×%d", itemName(names, item.ItemID), item.Quantity))
+	}
+	if len(parts) == 0 {
+		return "ничего"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// tradeText формирует описание предложения обмена для обеих сторон.
+func tradeText(trade *models.TradeOffer, names map[primitive.ObjectID]string) string {
+	return fmt.Sprintf("Предложение обмена\n%s (@%s) отдаёт: %s\n%s (@%s) отдаёт: %s\nДействует до %s",
+		trade.From.Name, trade.From.Username, goodsText(trade.Give, names),
+		trade.To.Name, trade.To.Username, goodsText(trade.Take, names),
+		trade.ExpiresAt.Format("15:04 02.01.2006"))
+}
+
+// tradeParty формирует участника обмена по анкете.
+func tradeParty(profile *models.UserProfile) models.TradeParty {
+	return models.TradeParty{TelegramID: profile.TelegramID, Username: profile.Username, Name: profile.Name}
+}
+
+// handleTrade создаёт предложение обмена и отправляет его получателю с кнопками ответа.
+// Формат: обмен @username (что отдаёте) на (что хотите получить).
+func handleTrade(bot Messenger, message *tgbotapi.Message, args string) {
+	fields := strings.Fields(args)
+	sep := -1
+	for i, field := range fields {
+		if i > 0 && strings.EqualFold(field, "на") {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || sep == 1 || sep == len(fields)-1 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Неверный формат. "+tradeUsage))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	give, err := parseTradeGoods(ctx, strings.Join(fields[1:sep], " "))
+	if err == nil {
+		var take models.TradeGoods
+		if take, err = parseTradeGoods(ctx, strings.Join(fields[sep+1:], " ")); err == nil {
+			createTrade(ctx, bot, message, fields[0], give, take)
+			return
+		}
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка: "+err.Error()+".\n"+tradeUsage))
+}
+
+// createTrade проверяет стороны обмена, сохраняет предложение и рассылает его участникам.
+func createTrade(ctx context.Context, bot Messenger, message *tgbotapi.Message, targetRef string, give, take models.TradeGoods) {
+	if give.Empty() && take.Empty() {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "В обмене ничего не передаётся."))
+		return
+	}
+	author, err := store.GetProfile(ctx, message.From.ID)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Анкета не найдена. Зарегистрируйтесь командой: регистрация"))
+		return
+	}
+	target, err := findProfileByRef(ctx, targetRef)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Профиль получателя не найден. Убедитесь, что пользователь зарегистрирован."))
+		return
+	}
+	if target.TelegramID == author.TelegramID {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Нельзя предложить обмен самому себе."))
+		return
+	}
+	if !give.HeldBy(author) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "У вас нет всего, что вы предлагаете. Проверьте баланс и инвентарь."))
+		return
+	}
+
+	now := time.Now()
+	trade := &models.TradeOffer{
+		CreatedAt: now,
+		ExpiresAt: now.Add(tradeOfferTimeout),
+		From:      tradeParty(author),
+		To:        tradeParty(target),
+		Give:      give,
+		Take:      take,
+		Status:    models.TradePending,
+	}
+	if err := store.CreateTrade(ctx, trade); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Ошибка при создании предложения обмена."))
+		return
+	}
+
+	text := tradeText(trade, itemNames(ctx))
+	offer := tgbotapi.NewMessage(target.TelegramID, text)
+	offer.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Принять", tradeCallbackData("accept", trade.ID)),
+		tgbotapi.NewInlineKeyboardButtonData("Отклонить", tradeCallbackData("decline", trade.ID)),
+	))
+	offerMsg, err := bot.Send(offer)
+	if err != nil {
+		log.Printf("Ошибка отправки предложения обмена %s пользователю %d: %v", trade.ID.Hex(), target.TelegramID, err)
+		if _, err := store.DecideTrade(ctx, trade.ID, models.TradeCancelled); err != nil {
+			log.Printf("Ошибка отмены предложения обмена %s: %v", trade.ID.Hex(), err)
+		}
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("Не удалось отправить предложение @%s: игрок должен сначала написать боту в личные сообщения.", target.Username)))
+		return
+	}
+
+	notice := tgbotapi.NewMessage(message.Chat.ID, text)
+	notice.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Отменить", tradeCallbackData("cancel", trade.ID)),
+	))
+	noticeMsg, _ := bot.Send(notice)
+	err = store.SetTradeMessages(ctx, trade.ID,
+		models.MessageRef{ChatID: target.TelegramID, MessageID: offerMsg.MessageID},
+		models.MessageRef{ChatID: message.Chat.ID, MessageID: noticeMsg.MessageID})
+	if err != nil {
+		log.Printf("Ошибка сохранения сообщений обмена %s: %v", trade.ID.Hex(), err)
+	}
+}
+
+// finishTrade убирает кнопки из сообщений предложения и дописывает в них итог.
+func finishTrade(ctx context.Context, bot Messenger, trade *models.TradeOffer, status string) {
+	text := tradeText(trade, itemNames(ctx)) + "\n\n" + status
+	for _, ref := range []models.MessageRef{trade.Offer, trade.Notice} {
+		if ref.MessageID == 0 {
+			continue
+		}
+		bot.Send(tgbotapi.NewEditMessageText(ref.ChatID, ref.MessageID, text))
+	}
+}
+
+// logTrade записывает в лог изменения ресурсов и предметов обеих сторон обмена.
+func logTrade(ctx context.Context, trade *models.TradeOffer, from, to *models.UserProfile) {
+	names := itemNames(ctx)
+	logGoods := func(donor, recipient *models.UserProfile, goods models.TradeGoods) {
+		for _, resource := range []string{models.ResourceOblomki, models.ResourcePiastry} {
+			if amount := goods.Amount(resource); amount > 0 {
+				AddLogEvent(*donor, -amount, models.ResourceLabel(resource))
+				AddLogEvent(*recipient, amount, models.ResourceLabel(resource))
+			}
+		}
+		for _, item := range goods.Items {
+			AddLogEvent(*donor, -item.Quantity, itemName(names, item.ItemID))
+			AddLogEvent(*recipient, item.Quantity, itemName(names, item.ItemID))
+		}
+	}
+	logGoods(from, to, trade.Give)
+	logGoods(to, from, trade.Take)
+}
+
+// HandleTradeCallback обрабатывает кнопки предложения обмена и отвечает всплывающим уведомлением.
+// Принять и отклонить может только получатель, отменить — только автор.
+// Формат данных: trade:<accept|decline|cancel>:<ID предложения>.
+func HandleTradeCallback(bot Messenger, cq *tgbotapi.CallbackQuery) {
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 3 {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Неизвестное действие."))
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(parts[2])
+	if err != nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Неизвестное предложение."))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	trade, err := store.GetTrade(ctx, id)
+	if err != nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Предложение не найдено."))
+		return
+	}
+	allowed := trade.To.TelegramID
+	if parts[1] == "cancel" {
+		allowed = trade.From.TelegramID
+	}
+	if cq.From.ID != allowed {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Это действие вам недоступно."))
+		return
+	}
+
+	switch parts[1] {
+	case "accept":
+		accepted, from, to, err := store.ExecuteTrade(ctx, id, time.Now())
+		switch {
+		case errors.Is(err, storage.ErrTradeExpired):
+			bot.Request(tgbotapi.NewCallback(cq.ID, "Предложение истекло."))
+		case errors.Is(err, storage.ErrAlreadyDecided):
+			bot.Request(tgbotapi.NewCallback(cq.ID, "Предложение уже не действует."))
+		case errors.Is(err, storage.ErrInsufficientFunds), errors.Is(err, storage.ErrInsufficientItems):
+			// Предложение остаётся в силе: недостающее можно докупить до истечения срока.
+			reply := "Обмен не состоялся: " + err.Error() + "."
+			bot.Request(tgbotapi.NewCallback(cq.ID, reply))
+			bot.Send(tgbotapi.NewMessage(trade.From.TelegramID,
+				fmt.Sprintf("@%s пытается принять ваш обмен. %s", trade.To.Username, reply)))
+		case err != nil:
+			log.Printf("Ошибка проведения обмена %s: %v", id.Hex(), err)
+			bot.Request(tgbotapi.NewCallback(cq.ID, "Ошибка при проведении обмена. Ничего не изменилось."))
+		default:
+			bot.Request(tgbotapi.NewCallback(cq.ID, "Обмен состоялся."))
+			logTrade(ctx, accepted, from, to)
+			finishTrade(ctx, bot, accepted, "Обмен состоялся.")
+		}
+	case "decline", "cancel":
+		status, result := models.TradeDeclined, fmt.Sprintf("Предложение отклонено @%s.", trade.To.Username)
+		if parts[1] == "cancel" {
+			status, result = models.TradeCancelled, "Предложение отменено автором."
+		}
+		decided, err := store.DecideTrade(ctx, id, status)
+		if errors.Is(err, storage.ErrAlreadyDecided) {
+			bot.Request(tgbotapi.NewCallback(cq.ID, "Предложение уже не действует."))
+			return
+		}
+		if err != nil {
+			bot.Request(tgbotapi.NewCallback(cq.ID, "Ошибка при обработке предложения."))
+			return
+		}
+		bot.Request(tgbotapi.NewCallback(cq.ID, result))
+		finishTrade(ctx, bot, decided, result)
+	default:
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Неизвестное действие."))
+	}
+}
+
+// expireTrades завершает просроченные предложения обмена и сообщает об этом участникам.
+func expireTrades(ctx context.Context, bot Messenger, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	expired, err := store.ExpireTrades(ctx, now)
+	if err != nil {
+		log.Printf("Ошибка завершения просроченных обменов: %v", err)
+	}
+	for i := range expired {
+		finishTrade(ctx, bot, &expired[i], "Предложение истекло.")
+	}
+}
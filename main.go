@@ -1,77 +1,201 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
-	"strings"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"telegram-bot-go/config"
 	"telegram-bot-go/db"
 	"telegram-bot-go/handlers"
+	"telegram-bot-go/pool"
+	"telegram-bot-go/storage"
+	"telegram-bot-go/webhook"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func main() {
-	// Токен бота (в продакшене рекомендуется использовать переменные окружения)
-	token := "7547569544:AAHzaDCxeEqJCuEjWKwjAzXG_Q8ZTWjbQsk"
+	// Путь к файлу конфигурации можно передать флагом -config или переменной BOT_CONFIG.
+	configPath := flag.String("config", os.Getenv("BOT_CONFIG"), "путь к YAML-файлу конфигурации")
+	flag.Parse()
 
-	bot, err := tgbotapi.NewBotAPI(token)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+
+	// ctx отменяется по SIGINT/SIGTERM — это сигнал к остановке приёма обновлений.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	bot, err := tgbotapi.NewBotAPI(cfg.Token)
 	if err != nil {
 		log.Fatalf("Ошибка создания бота: %v", err)
 	}
-	bot.Debug = true
+	bot.Debug = cfg.Debug
 	log.Printf("Запущен бот: %s", bot.Self.UserName)
 
 	// Подключение к MongoDB
-	mongoClient, err := db.ConnectMongo("mongodb://localhost:27017")
+	mongoClient, err := db.ConnectMongo(cfg.Mongo.URI)
 	if err != nil {
 		log.Fatalf("Ошибка подключения к MongoDB: %v", err)
 	}
 	// Получаем базу данных
-	database := mongoClient.Database("mydatabase")
+	database := mongoClient.Database(cfg.Mongo.Database)
 	if database == nil {
 		log.Fatal("Ошибка: База данных равна nil")
 	}
-	// Инициализируем обработчики, передав ссылку на базу данных
-	handlers.InitHandlers(database)
+	// Инициализируем хранилище поверх базы данных
+	store, err := storage.NewMongo(ctx, database)
+	if err != nil {
+		log.Fatalf("Ошибка инициализации хранилища: %v", err)
+	}
+	// Инициализируем обработчики, передав хранилище и конфигурацию
+	handlers.InitHandlers(store, cfg)
+
+	// Публикуем меню команд, сформированное из реестра команд.
+	if _, err := bot.Request(tgbotapi.NewSetMyCommands(handlers.CommandMenu()...)); err != nil {
+		log.Printf("Ошибка установки меню команд: %v", err)
+	}
+
+	// Обновления обрабатываются параллельно, но обновления одного пользователя — по порядку.
+	workers := pool.New(cfg.Workers, func(update tgbotapi.Update) {
+		handlers.HandleUpdate(bot, update)
+	})
+
+	// Планировщик ивентов публикует запланированные ивенты, рассылает напоминания и завершает истёкшие.
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		handlers.RunEventScheduler(ctx, bot)
+	}()
+
+	switch cfg.Mode {
+	case config.ModeWebhook:
+		runWebhook(ctx, bot, cfg, workers)
+	default:
+		runPolling(ctx, bot, store, workers)
+	}
+
+	// Приём обновлений остановлен: ждём обработчики, сохраняем смещение и закрываем базу.
+	log.Println("Остановка бота: ожидание завершения обработчиков...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := workers.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Не все обработчики завершились за %s: %v", cfg.ShutdownTimeout, err)
+	}
+	select {
+	case <-schedulerDone:
+	case <-shutdownCtx.Done():
+	}
+	if cfg.Mode == config.ModePolling {
+		if last := workers.LastProcessed(); last > 0 {
+			if err := store.SaveLastUpdateID(shutdownCtx, last); err != nil {
+				log.Printf("Ошибка сохранения ID последнего обновления: %v", err)
+			}
+		}
+	}
+	if err := mongoClient.Disconnect(shutdownCtx); err != nil {
+		log.Printf("Ошибка отключения от MongoDB: %v", err)
+	}
+	log.Println("Бот остановлен.")
+}
+
+// runPolling получает обновления через long polling, начиная с обновления,
+// следующего за последним обработанным, и возвращается после отмены ctx.
+func runPolling(ctx context.Context, bot *tgbotapi.BotAPI, store storage.State, workers *pool.Pool) {
+	// Пока установлен вебхук, Telegram не отдаёт обновления через getUpdates.
+	if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("Ошибка удаления вебхука: %v", err)
+	}
 
+	lastID, err := store.LastUpdateID(ctx)
+	if err != nil {
+		log.Printf("Ошибка чтения ID последнего обновления: %v", err)
+	}
 	u := tgbotapi.NewUpdate(0)
+	if lastID > 0 {
+		u.Offset = lastID + 1
+		log.Printf("Продолжаем получение обновлений с ID %d", u.Offset)
+	}
 	u.Timeout = 60
-	updates := bot.GetUpdatesChan(u)
 
-	for update := range updates {
-		if update.CallbackQuery != nil {
-			handlers.HandleCallbackQuery(bot, update.CallbackQuery)
-			continue
-		}
+	type result struct {
+		updates []tgbotapi.Update
+		err     error
+	}
+	for {
+		// Telegram считает обновления доставленными только при следующем запросе с большим offset,
+		// поэтому прерванный при остановке запрос не теряет обновления — они придут после перезапуска.
+		results := make(chan result, 1)
+		go func(config tgbotapi.UpdateConfig) {
+			updates, err := bot.GetUpdates(config)
+			results <- result{updates, err}
+		}(u)
 
-		if update.Message == nil {
-			continue
+		var res result
+		select {
+		case <-ctx.Done():
+			return
+		case res = <-results:
 		}
-
-		// Если сообщение начинается со слэша, убираем слэш и упоминание бота.
-		if strings.HasPrefix(update.Message.Text, "/") {
-			cmd := strings.TrimPrefix(update.Message.Text, "/")
-			if i := strings.Index(cmd, "@"); i != -1 {
-				cmd = cmd[:i]
+		if res.err != nil {
+			log.Printf("Ошибка получения обновлений, повтор через 3 секунды: %v", res.err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(3 * time.Second):
 			}
-			update.Message.Text = cmd
-
-			// Приводим команду к нижнему регистру для унифицированной обработки.
-			lowerCmd := strings.ToLower(cmd)
-			if strings.HasPrefix(lowerCmd, "начатьивент") {
-				handlers.HandleCreateEvent(bot, update.Message)
-			} else if lowerCmd == "живой" ||
-				lowerCmd == "список анкет" ||
-				lowerCmd == "полный список анкет" ||
-				strings.HasPrefix(lowerCmd, "анкета ") ||
-				strings.HasPrefix(lowerCmd, "датьадмин") ||
-				strings.HasPrefix(lowerCmd, "чек лог") {
-				handlers.HandleAdminCommand(bot, update.Message)
-			} else {
-				handlers.HandleCommand(bot, update.Message)
+			continue
+		}
+		for _, update := range res.updates {
+			if update.UpdateID >= u.Offset {
+				u.Offset = update.UpdateID + 1
+				workers.Submit(update)
 			}
-		} else {
-			handlers.HandleNonCommandMessage(bot, update.Message)
 		}
 	}
 }
+
+// runWebhook регистрирует вебхук и принимает обновления встроенным HTTP-сервером
+// до отмены ctx.
+func runWebhook(ctx context.Context, bot *tgbotapi.BotAPI, cfg *config.Config, workers *pool.Pool) {
+	if err := webhook.Register(bot, cfg.Webhook.URL, cfg.Webhook.Secret); err != nil {
+		log.Fatalf("Ошибка регистрации вебхука: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Webhook.Path, webhook.NewHandler(cfg.Webhook.Secret, workers.Submit))
+	server := &http.Server{
+		Addr:              cfg.Webhook.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Вебхук слушает %s%s", cfg.Webhook.Listen, cfg.Webhook.Path)
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Ошибка остановки HTTP-сервера вебхука: %v", err)
+		}
+	}()
+
+	var err error
+	if cfg.Webhook.CertFile != "" {
+		err = server.ListenAndServeTLS(cfg.Webhook.CertFile, cfg.Webhook.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Ошибка HTTP-сервера вебхука: %v", err)
+	}
+}
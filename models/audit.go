@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEntry — запись журнала действий администрации и других ролей. В отличие от логов
+// ресурсов записи журнала не удаляются и не изменяются.
+type AuditEntry struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	Date          time.Time          `bson:"date"`
+	ActorID       int64              `bson:"actor_id"`
+	ActorUsername string             `bson:"actor_username"`   // в нижнем регистре
+	Action        string             `bson:"action"`           // название команды или действия
+	Target        string             `bson:"target,omitempty"` // @username, ID анкеты, ивента или заявки
+	Args          string             `bson:"args,omitempty"`
+	Result        string             `bson:"result"` // ответ бота на действие
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Conversation хранит состояние многошагового диалога с пользователем (например, регистрации),
+// чтобы его можно было продолжить после перезапуска бота. У пользователя не больше одного диалога.
+type Conversation struct {
+	TelegramID int64             `bson:"_id"`
+	ChatID     int64             `bson:"chat_id"`          // диалог ведётся только в этом чате
+	Kind       string            `bson:"kind"`             // вид диалога, например "registration"
+	StepKey    string            `bson:"step_key"`         // ключ текущего шага: шаги могут меняться между ответами
+	Values     map[string]string `bson:"values,omitempty"` // ответы по ключам шагов
+	ExpiresAt  time.Time         `bson:"expires_at"`       // после этого момента диалог удаляется
+}
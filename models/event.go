@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Статусы ивента.
+const (
+	EventScheduled = "scheduled" // объявление будет опубликовано в StartDate
+	EventActive    = "active"
+	EventClosed    = "closed"
+)
+
+// Event описывает ивент, за участие в котором начисляется валюта.
+type Event struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	Name         string             `bson:"name"`
+	Status       string             `bson:"status"`
+	CreatedBy    int64              `bson:"created_by"`
+	Oblomki      int                `bson:"oblomki"`
+	Piastry      int                `bson:"piastry"`
+	StartDate    time.Time          `bson:"start_date"`
+	ChatID       int64              `bson:"chat_id"`           // чат, в котором публикуется объявление
+	EndsAt       time.Time          `bson:"ends_at,omitempty"` // время автоматического завершения; пустое — до команды завершения
+	ClosedAt     time.Time          `bson:"closed_at,omitempty"`
+	ClosedBy     int64              `bson:"closed_by,omitempty"` // 0 — ивент завершился по времени
+	ReminderSent bool               `bson:"reminder_sent"`
+	Announcement MessageRef         `bson:"announcement"` // сообщение с кнопками "Участвую"/"Пропуск"
+}
+
+// Expired сообщает, истекло ли время ивента к моменту now.
+func (e *Event) Expired(now time.Time) bool {
+	return !e.EndsAt.IsZero() && !now.Before(e.EndsAt)
+}
+
+// Ответы игрока на ивент.
+const (
+	ChoiceParticipate = "participate"
+	ChoiceSkip        = "skip"
+)
+
+// EventParticipant — ответ игрока на ивент. На каждого игрока в ивенте одна запись:
+// пропуск можно сменить на участие, но награда за участие начисляется один раз.
+type EventParticipant struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	EventID     primitive.ObjectID `bson:"event_id"`
+	TelegramID  int64              `bson:"telegram_id"`
+	Username    string             `bson:"username"`
+	Name        string             `bson:"name"`
+	Choice      string             `bson:"choice"`
+	RespondedAt time.Time          `bson:"responded_at"` // время последнего изменения ответа
+}
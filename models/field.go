@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Типы дополнительных полей анкеты.
+const (
+	FieldText     = "text"     // короткий текст
+	FieldLongText = "longtext" // длинный текст, например предыстория
+	FieldNumber   = "number"   // целое число
+)
+
+// ProfileField — дополнительное поле анкеты, которое задаёт администрация.
+// Значения полей хранятся в UserProfile.Fields по идентификатору поля.
+type ProfileField struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Key       string             `bson:"key"` // название в нижнем регистре, уникально (см. ItemKey)
+	Name      string             `bson:"name"`
+	Type      string             `bson:"type"`     // см. Field*
+	Required  bool               `bson:"required"` // при регистрации поле нельзя пропустить
+	Editable  bool               `bson:"editable"` // игрок заполняет поле сам; иначе его заполняет администрация
+	CreatedAt time.Time          `bson:"created_at"`
+	CreatedBy int64              `bson:"created_by"`
+}
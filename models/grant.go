@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Статусы заявки на пополнение ресурса.
+const (
+	GrantPending  = "pending"
+	GrantApproved = "approved"
+	GrantRejected = "rejected"
+)
+
+// GrantRequest — заявка игрока на пополнение своего баланса, которую рассматривает администрация.
+type GrantRequest struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	TelegramID    int64              `bson:"telegram_id"`
+	Username      string             `bson:"username"`
+	Name          string             `bson:"name"`
+	Resource      string             `bson:"resource"`       // oblomki или piastry
+	ResourceLabel string             `bson:"resource_label"` // название ресурса в том виде, в каком его ввёл игрок
+	Amount        int                `bson:"amount"`
+	Status        string             `bson:"status"`
+	DecidedBy     int64              `bson:"decided_by,omitempty"`
+	DecidedAt     time.Time          `bson:"decided_at,omitempty"`
+	Notifications []MessageRef       `bson:"notifications,omitempty"` // уведомления, разосланные администрации
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Item — предмет из каталога, который ведёт администрация.
+type Item struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Key         string             `bson:"key"` // название в нижнем регистре, уникально
+	Name        string             `bson:"name"`
+	Description string             `bson:"description"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	CreatedBy   int64              `bson:"created_by"`
+}
+
+// ItemKey возвращает ключ, по которому предмет ищется в каталоге: название без учёта регистра и лишних пробелов.
+func ItemKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// InventoryItem — предметы одного вида в инвентаре персонажа.
+type InventoryItem struct {
+	ItemID   primitive.ObjectID `bson:"item_id"`
+	Quantity int                `bson:"quantity"`
+}
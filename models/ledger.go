@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Виды операций в реестре движения валюты.
+const (
+	LedgerTransfer = "transfer" // передача между игроками
+	LedgerPurchase = "purchase" // покупка в магазине; получатель — магазин
+	LedgerTrade    = "trade"    // валюта, переданная в обмене между игроками
+	LedgerEvent    = "event"    // награда за участие в ивенте; отправитель — ивент
+	LedgerGrant    = "grant"    // пополнение по одобренной заявке; отправитель — одобривший администратор
+	LedgerDebit    = "debit"    // списание игроком собственных ресурсов; получателя нет
+)
+
+// LedgerParty описывает одну сторону операции и её баланс после операции.
+type LedgerParty struct {
+	TelegramID   int64  `bson:"telegram_id"`
+	Username     string `bson:"username"`
+	Name         string `bson:"name"`
+	BalanceAfter int    `bson:"balance_after"`
+}
+
+// LedgerEntry — запись реестра о движении обломков или пиастр. В отличие от логов
+// записи реестра не удаляются и фиксируют обе стороны операции.
+type LedgerEntry struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Date     time.Time          `bson:"date"`
+	Kind     string             `bson:"kind"`
+	Resource string             `bson:"resource"` // oblomki или piastry
+	Amount   int                `bson:"amount"`
+	From     LedgerParty        `bson:"from"`
+	To       LedgerParty        `bson:"to"`
+}
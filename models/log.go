@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Названия полей ресурсов в анкете.
+const (
+	ResourceOblomki = "oblomki"
+	ResourcePiastry = "piastry"
+)
+
+// resourceLabels — названия ресурсов, которыми пользуются игроки.
+var resourceLabels = map[string]string{
+	ResourceOblomki: "обломки",
+	ResourcePiastry: "пиастры",
+}
+
+// ResourceLabel возвращает название ресурса для вывода ("обломки" или "пиастры").
+func ResourceLabel(resource string) string {
+	if label, ok := resourceLabels[resource]; ok {
+		return label
+	}
+	return resource
+}
+
+// ParseResource определяет ресурс по названию, введённому пользователем.
+func ParseResource(label string) (string, bool) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	for resource, l := range resourceLabels {
+		if l == label {
+			return resource, true
+		}
+	}
+	return "", false
+}
+
+// LogEntry описывает запись об изменении ресурса пользователя.
+type LogEntry struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	Date         time.Time          `bson:"date"`
+	TelegramID   int64              `bson:"telegram_id"`
+	Username     string             `bson:"username"`
+	Name         string             `bson:"name"`
+	ChangeAmount int                `bson:"change_amount"`
+	Resource     string             `bson:"resource"` // название ресурса в том виде, в каком его ввёл пользователь
+}
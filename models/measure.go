@@ -0,0 +1,48 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ageRe — возраст: целое число, возможно со словом "лет", "год" или "года".
+	ageRe = regexp.MustCompile(`^(\d+)\s*(?:лет|года?)?$`)
+	// heightWeightRe — рост и вес: два числа с необязательными единицами "см" и "кг",
+	// разделённые пробелом или одним из символов \ / , ; x х ×.
+	heightWeightRe = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*(?:см|cm)?\s*[\\/,;xх×\s]\s*(\d+(?:[.,]\d+)?)\s*(?:кг|kg)?$`)
+)
+
+// ParseAge разбирает возраст вида "30" или "30 лет".
+func ParseAge(s string) (int, bool) {
+	m := ageRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, false
+	}
+	age, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return age, true
+}
+
+// ParseHeightWeight разбирает рост в сантиметрах и вес в килограммах из строки
+// вида "173.6 см\70 кг", "173.6 см, 70 кг", "173,6/70" или "180 80".
+func ParseHeightWeight(s string) (heightCm, weightKg float64, ok bool) {
+	m := heightWeightRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, 0, false
+	}
+	h, err1 := strconv.ParseFloat(strings.Replace(m[1], ",", ".", 1), 64)
+	w, err2 := strconv.ParseFloat(strings.Replace(m[2], ",", ".", 1), 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return h, w, true
+}
+
+// FormatHeightWeight записывает рост и вес для анкеты: "173.6 см, 70 кг".
+func FormatHeightWeight(heightCm, weightKg float64) string {
+	return strconv.FormatFloat(heightCm, 'f', -1, 64) + " см, " + strconv.FormatFloat(weightKg, 'f', -1, 64) + " кг"
+}
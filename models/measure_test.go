@@ -0,0 +1,65 @@
+package models
+
+import "testing"
+
+// Строки ниже взяты из прежнего формата анкет, где возраст, рост и вес вводились свободным текстом.
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int
+		wantOK bool
+	}{
+		{"30", 30, true},
+		{"30 лет", 30, true},
+		{"21 год", 21, true},
+		{"22 года", 22, true},
+		{" 45 ЛЕТ ", 45, true},
+		{"300лет", 300, true},
+		{"около 300", 0, false},
+		{"бессмертный", 0, false},
+		{"25-30", 0, false},
+		{"-5", 0, false},
+		{"18+", 0, false},
+		{"99999999999999999999", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseAge(tt.in)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("ParseAge(%q) = %d, %v; ожидалось %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseHeightWeight(t *testing.T) {
+	tests := []struct {
+		in             string
+		height, weight float64
+		wantOK         bool
+	}{
+		{`173.6 см\70 кг`, 173.6, 70, true},
+		{`173.6см\70кг`, 173.6, 70, true},
+		{"173.6 см, 70 кг", 173.6, 70, true},
+		{"173,6/70", 173.6, 70, true},
+		{"185,80", 185, 80, true},
+		{"180 80", 180, 80, true},
+		{"180см/75кг", 180, 75, true},
+		{"190 x 95", 190, 95, true},
+		{"190х95", 190, 95, true},
+		{"175 СМ; 70 КГ", 175, 70, true},
+		{"165 cm / 55 kg", 165, 55, true},
+		{"Рост 180, вес 80", 0, 0, false},
+		{"высокий и худой", 0, 0, false},
+		{"180", 0, 0, false},
+		{"180 см", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		height, weight, ok := ParseHeightWeight(tt.in)
+		if ok != tt.wantOK || height != tt.height || weight != tt.weight {
+			t.Errorf("ParseHeightWeight(%q) = %v, %v, %v; ожидалось %v, %v, %v",
+				tt.in, height, weight, ok, tt.height, tt.weight, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package models
+
+// MessageRef указывает на отправленное ботом сообщение, чтобы его можно было отредактировать позже.
+type MessageRef struct {
+	ChatID    int64 `bson:"chat_id"`
+	MessageID int   `bson:"message_id"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "strings"
+
+// Роли пользователей. Игрок — роль по умолчанию и не хранится в анкете;
+// владелец задаётся в конфигурации (owner_id) и не назначается командой.
+const (
+	RoleOwner      = "owner"
+	RoleAdmin      = "admin"
+	RoleGameMaster = "gamemaster"
+	RoleTreasurer  = "treasurer"
+	RolePlayer     = "player"
+)
+
+// roleLabels — названия ролей для вывода пользователю.
+var roleLabels = map[string]string{
+	RoleOwner:      "владелец",
+	RoleAdmin:      "администратор",
+	RoleGameMaster: "гейм-мастер",
+	RoleTreasurer:  "казначей",
+	RolePlayer:     "игрок",
+}
+
+// roleAliases сопоставляет названия ролей, которые можно указать в командах, с ролями.
+var roleAliases = map[string]string{
+	"владелец":      RoleOwner,
+	"owner":         RoleOwner,
+	"админ":         RoleAdmin,
+	"администратор": RoleAdmin,
+	"admin":         RoleAdmin,
+	"гм":            RoleGameMaster,
+	"гейм-мастер":   RoleGameMaster,
+	"геймастер":     RoleGameMaster,
+	"гейммастер":    RoleGameMaster,
+	"gamemaster":    RoleGameMaster,
+	"казначей":      RoleTreasurer,
+	"treasurer":     RoleTreasurer,
+	"игрок":         RolePlayer,
+	"player":        RolePlayer,
+}
+
+// ParseRole возвращает роль по её названию (без учёта регистра).
+func ParseRole(name string) (string, bool) {
+	role, ok := roleAliases[strings.ToLower(strings.TrimSpace(name))]
+	return role, ok
+}
+
+// RoleLabel возвращает название роли для вывода пользователю.
+func RoleLabel(role string) string {
+	if label, ok := roleLabels[role]; ok {
+		return label
+	}
+	return role
+}
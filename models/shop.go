@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StockUnlimited означает, что запас товара в магазине не ограничен.
+const StockUnlimited = -1
+
+// ShopListing — товар магазина: предмет из каталога с ценой в одной из валют.
+type ShopListing struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ItemID    primitive.ObjectID `bson:"item_id"`  // у предмета не больше одного товара
+	Resource  string             `bson:"resource"` // ResourceOblomki или ResourcePiastry
+	Price     int                `bson:"price"`    // цена одной штуки
+	Stock     int                `bson:"stock"`    // сколько осталось; StockUnlimited — без ограничения
+	CreatedAt time.Time          `bson:"created_at"`
+	CreatedBy int64              `bson:"created_by"`
+}
+
+// InStock сообщает, хватит ли запаса на покупку quantity штук.
+func (l *ShopListing) InStock(quantity int) bool {
+	return l.Stock == StockUnlimited || l.Stock >= quantity
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Статусы предложения обмена.
+const (
+	TradePending   = "pending"
+	TradeAccepted  = "accepted"
+	TradeDeclined  = "declined"
+	TradeCancelled = "cancelled"
+	TradeExpired   = "expired"
+)
+
+// TradeGoods — то, что одна сторона отдаёт в обмене: валюта и предметы.
+type TradeGoods struct {
+	Oblomki int             `bson:"oblomki,omitempty"`
+	Piastry int             `bson:"piastry,omitempty"`
+	Items   []InventoryItem `bson:"items,omitempty"`
+}
+
+// Empty сообщает, что сторона ничего не отдаёт.
+func (g TradeGoods) Empty() bool {
+	return g.Oblomki == 0 && g.Piastry == 0 && len(g.Items) == 0
+}
+
+// Amount возвращает количество ресурса (ResourceOblomki или ResourcePiastry).
+func (g TradeGoods) Amount(resource string) int {
+	switch resource {
+	case ResourceOblomki:
+		return g.Oblomki
+	case ResourcePiastry:
+		return g.Piastry
+	}
+	return 0
+}
+
+// HeldBy сообщает, есть ли у анкеты всё, что перечислено в goods.
+func (g TradeGoods) HeldBy(profile *UserProfile) bool {
+	if profile.Oblomki < g.Oblomki || profile.Piastry < g.Piastry {
+		return false
+	}
+	for _, item := range g.Items {
+		if profile.ItemQuantity(item.ItemID) < item.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// TradeParty описывает участника обмена.
+type TradeParty struct {
+	TelegramID int64  `bson:"telegram_id"`
+	Username   string `bson:"username"`
+	Name       string `bson:"name"`
+}
+
+// TradeOffer — предложение обмена: From отдаёт Give в обмен на Take от To.
+type TradeOffer struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	From      TradeParty         `bson:"from"`
+	To        TradeParty         `bson:"to"`
+	Give      TradeGoods         `bson:"give"`
+	Take      TradeGoods         `bson:"take"`
+	Status    string             `bson:"status"`
+	DecidedAt time.Time          `bson:"decided_at,omitempty"`
+	Offer     MessageRef         `bson:"offer,omitempty"`  // сообщение получателю с кнопками
+	Notice    MessageRef         `bson:"notice,omitempty"` // сообщение автору с кнопкой отмены
+}
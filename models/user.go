@@ -1,24 +1,109 @@
 package models
 
 import (
+	"strconv"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // UserProfile описывает анкету пользователя.
 type UserProfile struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty"`
-	TelegramID   int64              `bson:"telegram_id"`
-	Username     string             `bson:"username"` // хранится в нижнем регистре
-	Name         string             `bson:"name"`
-	Race         string             `bson:"race"`
-	Age          string             `bson:"age"`
-	HeightWeight string             `bson:"height_weight"` // пример: "173.6 см\\70 кг"
-	Gender       string             `bson:"gender"`
-	PhotoFileID  string             `bson:"photo_file_id"`
-	Rank         string             `bson:"rank"`      // по умолчанию "Ис"
-	Team         string             `bson:"team"`      // по умолчанию "Наемник"
-	Oblomki      int                `bson:"oblomki"`   // по умолчанию 0
-	Piastry      int                `bson:"piastry"`   // по умолчанию 0
-	Inventory    string             `bson:"inventory"` // по умолчанию "Пусто"
-	IsAdmin      bool               `bson:"is_admin"`  // флаг администратора
+	ID               primitive.ObjectID `bson:"_id,omitempty"`
+	TelegramID       int64              `bson:"telegram_id"`
+	Username         string             `bson:"username"` // хранится в нижнем регистре
+	Name             string             `bson:"name"`
+	Race             string             `bson:"race"`
+	Age              int                `bson:"age,omitempty"`       // лет; 0 — не указан
+	HeightCm         float64            `bson:"height_cm,omitempty"` // рост в сантиметрах; 0 — не указан
+	WeightKg         float64            `bson:"weight_kg,omitempty"` // вес в килограммах; 0 — не указан
+	Gender           string             `bson:"gender"`
+	PhotoFileID      string             `bson:"photo_file_id"`
+	Rank             string             `bson:"rank"`                         // по умолчанию "Ис"
+	Team             string             `bson:"team"`                         // по умолчанию "Наемник"
+	Oblomki          int                `bson:"oblomki"`                      // по умолчанию 0
+	Piastry          int                `bson:"piastry"`                      // по умолчанию 0
+	Items            []InventoryItem    `bson:"items,omitempty"`              // предметы из каталога с количеством
+	InventoryNote    string             `bson:"inventory_note,omitempty"`     // прежний инвентарь в свободной форме
+	AgeNote          string             `bson:"age_note,omitempty"`           // прежний возраст, который не удалось разобрать
+	HeightWeightNote string             `bson:"height_weight_note,omitempty"` // прежние рост и вес, которые не удалось разобрать
+	Roles            []string           `bson:"roles,omitempty"`              // роли сверх роли игрока (см. Role*)
+	Fields           map[string]string  `bson:"fields,omitempty"`             // дополнительные поля по ID поля (см. ProfileField)
+}
+
+// HasRole сообщает, назначена ли анкете роль.
+func (p *UserProfile) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AgeText возвращает возраст для анкеты: число лет или прежнюю запись, если её не удалось разобрать.
+func (p *UserProfile) AgeText() string {
+	if p.Age > 0 {
+		return strconv.Itoa(p.Age)
+	}
+	return p.AgeNote
+}
+
+// HeightWeightText возвращает рост и вес для анкеты (см. FormatHeightWeight)
+// или прежнюю запись, если её не удалось разобрать.
+func (p *UserProfile) HeightWeightText() string {
+	if p.HeightCm > 0 || p.WeightKg > 0 {
+		return FormatHeightWeight(p.HeightCm, p.WeightKg)
+	}
+	return p.HeightWeightNote
+}
+
+// ItemQuantity возвращает количество предметов itemID в инвентаре.
+func (p *UserProfile) ItemQuantity(itemID primitive.ObjectID) int {
+	for _, item := range p.Items {
+		if item.ItemID == itemID {
+			return item.Quantity
+		}
+	}
+	return 0
+}
+
+// AddItem изменяет количество предметов itemID в инвентаре на delta. Предметы,
+// которых не осталось, убираются из инвентаря.
+func (p *UserProfile) AddItem(itemID primitive.ObjectID, delta int) {
+	items := make([]InventoryItem, 0, len(p.Items)+1)
+	found := false
+	for _, item := range p.Items {
+		if item.ItemID == itemID {
+			item.Quantity += delta
+			found = true
+		}
+		if item.Quantity > 0 {
+			items = append(items, item)
+		}
+	}
+	if !found && delta > 0 {
+		items = append(items, InventoryItem{ItemID: itemID, Quantity: delta})
+	}
+	p.Items = items
+}
+
+// Balance возвращает количество ресурса (ResourceOblomki или ResourcePiastry) в анкете.
+func (p *UserProfile) Balance(resource string) int {
+	switch resource {
+	case ResourceOblomki:
+		return p.Oblomki
+	case ResourcePiastry:
+		return p.Piastry
+	}
+	return 0
+}
+
+// AddBalance изменяет количество ресурса в анкете на delta.
+func (p *UserProfile) AddBalance(resource string, delta int) {
+	switch resource {
+	case ResourceOblomki:
+		p.Oblomki += delta
+	case ResourcePiastry:
+		p.Piastry += delta
+	}
 }
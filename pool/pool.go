@@ -0,0 +1,136 @@
+package pool
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Pool обрабатывает обновления параллельно, сохраняя порядок обработки
+// обновлений одного пользователя: у каждого пользователя своя очередь,
+// которую в каждый момент времени разбирает не более одной горутины.
+// Общее число одновременно работающих обработчиков ограничено размером пула.
+type Pool struct {
+	handle func(tgbotapi.Update)
+	sem    chan struct{}
+
+	mu       sync.Mutex
+	queues   map[int64][]tgbotapi.Update // наличие ключа означает, что очередь пользователя уже разбирается
+	inflight map[int]struct{}            // ID принятых, но ещё не обработанных обновлений
+	maxID    int                         // наибольший принятый ID обновления
+	wg       sync.WaitGroup
+}
+
+// New создаёт пул, который одновременно выполняет не более workers обработчиков.
+func New(workers int, handle func(tgbotapi.Update)) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		handle:   handle,
+		sem:      make(chan struct{}, workers),
+		queues:   make(map[int64][]tgbotapi.Update),
+		inflight: make(map[int]struct{}),
+	}
+}
+
+// Submit ставит обновление в очередь его пользователя.
+func (p *Pool) Submit(update tgbotapi.Update) {
+	key := updateKey(update)
+	p.mu.Lock()
+	queue, active := p.queues[key]
+	p.queues[key] = append(queue, update)
+	p.inflight[update.UpdateID] = struct{}{}
+	if update.UpdateID > p.maxID {
+		p.maxID = update.UpdateID
+	}
+	if !active {
+		p.wg.Add(1)
+		go p.run(key)
+	}
+	p.mu.Unlock()
+}
+
+// Wait блокируется, пока не будут обработаны все поставленные в очередь обновления.
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+// Shutdown ждёт завершения обработки всех обновлений, но не дольше, чем живёт ctx.
+// Возвращает ошибку контекста, если дождаться не удалось.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LastProcessed возвращает наибольший ID обновления, до которого включительно
+// все принятые обновления уже обработаны (0, если обновлений не было).
+func (p *Pool) LastProcessed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last := p.maxID
+	for id := range p.inflight {
+		if id-1 < last {
+			last = id - 1
+		}
+	}
+	return last
+}
+
+// run последовательно обрабатывает очередь одного пользователя, пока она не опустеет.
+func (p *Pool) run(key int64) {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		queue := p.queues[key]
+		if len(queue) == 0 {
+			delete(p.queues, key)
+			p.mu.Unlock()
+			return
+		}
+		update := queue[0]
+		p.queues[key] = queue[1:]
+		p.mu.Unlock()
+
+		p.sem <- struct{}{}
+		p.safeHandle(update)
+		<-p.sem
+
+		p.mu.Lock()
+		delete(p.inflight, update.UpdateID)
+		p.mu.Unlock()
+	}
+}
+
+// safeHandle вызывает обработчик и не даёт панике в нём остановить весь бот.
+func (p *Pool) safeHandle(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Паника при обработке обновления %d: %v", update.UpdateID, r)
+		}
+	}()
+	p.handle(update)
+}
+
+// updateKey возвращает идентификатор пользователя, по которому упорядочиваются обновления.
+// Для обновлений без отправителя используется идентификатор чата.
+func updateKey(update tgbotapi.Update) int64 {
+	if user := update.SentFrom(); user != nil {
+		return user.ID
+	}
+	if chat := update.FromChat(); chat != nil {
+		return chat.ID
+	}
+	return 0
+}
@@ -0,0 +1,204 @@
+package pool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// waitTimeout — сколько ждать события, которое должно произойти.
+const waitTimeout = 2 * time.Second
+
+// quietPeriod — сколько ждать, чтобы убедиться, что событие не происходит.
+const quietPeriod = 50 * time.Millisecond
+
+// blockingHandler сообщает о начале обработки каждого обновления и не завершает её,
+// пока тест не отпустит это обновление.
+type blockingHandler struct {
+	started chan int
+
+	mu      sync.Mutex
+	release map[int]chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{
+		started: make(chan int, 100),
+		release: make(map[int]chan struct{}),
+	}
+}
+
+// gate возвращает канал, закрытие которого завершает обработку обновления id.
+func (h *blockingHandler) gate(id int) chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.release[id] == nil {
+		h.release[id] = make(chan struct{})
+	}
+	return h.release[id]
+}
+
+func (h *blockingHandler) handle(update tgbotapi.Update) {
+	h.started <- update.UpdateID
+	<-h.gate(update.UpdateID)
+}
+
+// done завершает обработку обновления id.
+func (h *blockingHandler) done(id int) {
+	close(h.gate(id))
+}
+
+// expectStarted ждёт начала обработки обновлений ids в любом порядке.
+func (h *blockingHandler) expectStarted(t *testing.T, ids ...int) {
+	t.Helper()
+	want := make(map[int]bool)
+	for _, id := range ids {
+		want[id] = true
+	}
+	for range ids {
+		select {
+		case id := <-h.started:
+			if !want[id] {
+				t.Fatalf("началась обработка обновления %d, ожидались %v", id, ids)
+			}
+			delete(want, id)
+		case <-time.After(waitTimeout):
+			t.Fatalf("не началась обработка обновлений %v", ids)
+		}
+	}
+}
+
+// next ждёт начала обработки очередного обновления и возвращает его ID.
+func (h *blockingHandler) next(t *testing.T) int {
+	t.Helper()
+	select {
+	case id := <-h.started:
+		return id
+	case <-time.After(waitTimeout):
+		t.Fatal("не началась обработка очередного обновления")
+		return 0
+	}
+}
+
+// expectIdle проверяет, что обработка новых обновлений не начинается.
+func (h *blockingHandler) expectIdle(t *testing.T) {
+	t.Helper()
+	select {
+	case id := <-h.started:
+		t.Fatalf("неожиданно началась обработка обновления %d", id)
+	case <-time.After(quietPeriod):
+	}
+}
+
+// message формирует обновление id с сообщением пользователя from.
+func message(id int, from int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: id,
+		Message: &tgbotapi.Message{
+			From: &tgbotapi.User{ID: from},
+			Chat: &tgbotapi.Chat{ID: from},
+		},
+	}
+}
+
+func TestPoolKeepsUserOrder(t *testing.T) {
+	h := newBlockingHandler()
+	p := New(4, h.handle)
+	p.Submit(message(1, 100))
+	p.Submit(message(2, 100))
+	p.Submit(message(3, 200))
+
+	// Обновления разных пользователей обрабатываются параллельно,
+	// а второе обновление пользователя ждёт окончания первого.
+	h.expectStarted(t, 1, 3)
+	h.expectIdle(t)
+	h.done(1)
+	h.expectStarted(t, 2)
+	h.done(2)
+	h.done(3)
+	p.Wait()
+}
+
+func TestPoolLimitsConcurrency(t *testing.T) {
+	h := newBlockingHandler()
+	p := New(2, h.handle)
+	for id := 1; id <= 4; id++ {
+		p.Submit(message(id, int64(100+id)))
+	}
+
+	// Одновременно работают не больше двух обработчиков; остальные ждут свободного места.
+	first, second := h.next(t), h.next(t)
+	h.expectIdle(t)
+	h.done(first)
+	third := h.next(t)
+	h.expectIdle(t)
+	h.done(second)
+	fourth := h.next(t)
+	h.done(third)
+	h.done(fourth)
+	p.Wait()
+}
+
+func TestPoolRecoversFromPanic(t *testing.T) {
+	var mu sync.Mutex
+	var handled []int
+	p := New(1, func(update tgbotapi.Update) {
+		if update.UpdateID == 1 {
+			panic("сломанный обработчик")
+		}
+		mu.Lock()
+		handled = append(handled, update.UpdateID)
+		mu.Unlock()
+	})
+	p.Submit(message(1, 100))
+	p.Submit(message(2, 100))
+	p.Submit(message(3, 200))
+	p.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 2 {
+		t.Errorf("обработаны обновления %v, ожидались 2 и 3", handled)
+	}
+	if last := p.LastProcessed(); last != 3 {
+		t.Errorf("LastProcessed = %d, ожидалось 3", last)
+	}
+}
+
+func TestPoolLastProcessed(t *testing.T) {
+	h := newBlockingHandler()
+	p := New(4, h.handle)
+	if last := p.LastProcessed(); last != 0 {
+		t.Fatalf("LastProcessed без обновлений = %d, ожидалось 0", last)
+	}
+	p.Submit(message(10, 100))
+	p.Submit(message(11, 200))
+	p.Submit(message(12, 300))
+	h.expectStarted(t, 10, 11, 12)
+
+	// Обновление 11 ещё обрабатывается: подтверждать можно только то, что ниже него.
+	h.done(10)
+	h.done(12)
+	waitLastProcessed(t, p, 10)
+	time.Sleep(quietPeriod)
+	if last := p.LastProcessed(); last != 10 {
+		t.Fatalf("LastProcessed = %d при необработанном обновлении 11", last)
+	}
+	h.done(11)
+	waitLastProcessed(t, p, 12)
+	p.Wait()
+}
+
+// waitLastProcessed ждёт, пока LastProcessed станет равным want.
+func waitLastProcessed(t *testing.T, p *Pool, want int) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for p.LastProcessed() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("LastProcessed = %d, ожидалось %d", p.LastProcessed(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
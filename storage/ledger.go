@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"time"
+
+	"telegram-bot-go/models"
+)
+
+// ledgerParty формирует сторону операции по анкете после изменения баланса.
+func ledgerParty(profile *models.UserProfile, resource string) models.LedgerParty {
+	return models.LedgerParty{
+		TelegramID:   profile.TelegramID,
+		Username:     profile.Username,
+		Name:         profile.Name,
+		BalanceAfter: profile.Balance(resource),
+	}
+}
+
+// newTransferEntry формирует запись реестра о передаче ресурса между игроками.
+func newTransferEntry(donor, recipient *models.UserProfile, resource string, amount int) models.LedgerEntry {
+	return newMovementEntry(models.LedgerTransfer, donor, recipient, resource, amount)
+}
+
+// newMovementEntry формирует запись реестра указанного вида о движении ресурса между игроками.
+func newMovementEntry(kind string, donor, recipient *models.UserProfile, resource string, amount int) models.LedgerEntry {
+	return models.LedgerEntry{
+		Date:     time.Now(),
+		Kind:     kind,
+		Resource: resource,
+		Amount:   amount,
+		From:     ledgerParty(donor, resource),
+		To:       ledgerParty(recipient, resource),
+	}
+}
+
+// newPurchaseEntry формирует запись реестра о покупке в магазине.
+func newPurchaseEntry(buyer *models.UserProfile, resource string, amount int) models.LedgerEntry {
+	return models.LedgerEntry{
+		Date:     time.Now(),
+		Kind:     models.LedgerPurchase,
+		Resource: resource,
+		Amount:   amount,
+		From:     ledgerParty(buyer, resource),
+		To:       models.LedgerParty{Name: "Магазин"},
+	}
+}
+
+// newDebitEntry формирует запись реестра о списании ресурса с баланса игрока.
+func newDebitEntry(profile *models.UserProfile, resource string, amount int) models.LedgerEntry {
+	return models.LedgerEntry{
+		Date:     time.Now(),
+		Kind:     models.LedgerDebit,
+		Resource: resource,
+		Amount:   amount,
+		From:     ledgerParty(profile, resource),
+		To:       models.LedgerParty{Name: "Списание"},
+	}
+}
+
+// newGrantEntry формирует запись реестра о зачислении по одобренной заявке.
+func newGrantEntry(request *models.GrantRequest, recipient *models.UserProfile) models.LedgerEntry {
+	return models.LedgerEntry{
+		Date:     time.Now(),
+		Kind:     models.LedgerGrant,
+		Resource: request.Resource,
+		Amount:   request.Amount,
+		From:     models.LedgerParty{TelegramID: request.DecidedBy, Name: "Администрация"},
+		To:       ledgerParty(recipient, request.Resource),
+	}
+}
+
+// eventRewardRecords формирует записи реестра и журнала о награде за ивент, начисленной участнику.
+// Для ресурса, которого в награде нет, записи не создаются.
+func eventRewardRecords(event *models.Event, participant *models.UserProfile) ([]models.LedgerEntry, []models.LogEntry) {
+	var entries []models.LedgerEntry
+	var logs []models.LogEntry
+	now := time.Now()
+	for _, reward := range []struct {
+		resource string
+		amount   int
+	}{
+		{models.ResourceOblomki, event.Oblomki},
+		{models.ResourcePiastry, event.Piastry},
+	} {
+		if reward.amount == 0 {
+			continue
+		}
+		entries = append(entries, models.LedgerEntry{
+			Date:     now,
+			Kind:     models.LedgerEvent,
+			Resource: reward.resource,
+			Amount:   reward.amount,
+			From:     models.LedgerParty{Name: "Ивент «" + event.Name + "»"},
+			To:       ledgerParty(participant, reward.resource),
+		})
+		logs = append(logs, models.LogEntry{
+			Date:         now,
+			TelegramID:   participant.TelegramID,
+			Username:     participant.Username,
+			Name:         participant.Name,
+			ChangeAmount: reward.amount,
+			Resource:     models.ResourceLabel(reward.resource),
+		})
+	}
+	return entries, logs
+}
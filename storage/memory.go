@@ -0,0 +1,1036 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/models"
+)
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// MemoryStorage реализует Storage в памяти процесса. Используется в тестах
+// и для локального запуска без MongoDB.
+type MemoryStorage struct {
+	mu            sync.Mutex
+	profiles      []models.UserProfile
+	logs          []models.LogEntry
+	events        []models.Event
+	lastID        int
+	ledger        []models.LedgerEntry
+	grants        []models.GrantRequest
+	participants  []models.EventParticipant
+	audit         []models.AuditEntry
+	items         []models.Item
+	listings      []models.ShopListing
+	trades        []models.TradeOffer
+	conversations map[int64]models.Conversation
+	fields        []models.ProfileField
+}
+
+// NewMemory создаёт пустое хранилище в памяти.
+func NewMemory() *MemoryStorage {
+	return &MemoryStorage{conversations: make(map[int64]models.Conversation)}
+}
+
+// profileIndex возвращает индекс анкеты, удовлетворяющей условию, или -1.
+func (s *MemoryStorage) profileIndex(match func(p *models.UserProfile) bool) int {
+	for i := range s.profiles {
+		if match(&s.profiles[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findProfile возвращает копию анкеты, удовлетворяющей условию.
+func (s *MemoryStorage) findProfile(match func(p *models.UserProfile) bool) (*models.UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.profileIndex(match)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	profile := copyProfile(s.profiles[i])
+	return &profile, nil
+}
+
+// copyProfile копирует анкету вместе с ролями, инвентарём и дополнительными полями, чтобы вызывающий
+// и хранилище не делили одни и те же срезы и карты.
+func copyProfile(p models.UserProfile) models.UserProfile {
+	p.Roles = append([]string(nil), p.Roles...)
+	p.Items = append([]models.InventoryItem(nil), p.Items...)
+	p.Fields = copyValues(p.Fields)
+	return p
+}
+
+func (s *MemoryStorage) GetProfile(ctx context.Context, telegramID int64) (*models.UserProfile, error) {
+	return s.findProfile(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+}
+
+func (s *MemoryStorage) GetProfileByUsername(ctx context.Context, username string) (*models.UserProfile, error) {
+	return s.findProfile(func(p *models.UserProfile) bool { return p.Username == username })
+}
+
+func (s *MemoryStorage) GetProfileByID(ctx context.Context, id primitive.ObjectID) (*models.UserProfile, error) {
+	return s.findProfile(func(p *models.UserProfile) bool { return p.ID == id })
+}
+
+func (s *MemoryStorage) ListProfiles(ctx context.Context, order ProfileSort) ([]models.UserProfile, error) {
+	s.mu.Lock()
+	profiles := make([]models.UserProfile, len(s.profiles))
+	for i, p := range s.profiles {
+		profiles[i] = copyProfile(p)
+	}
+	s.mu.Unlock()
+
+	switch order {
+	case SortByName:
+		sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	case SortByOblomki:
+		sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Oblomki > profiles[j].Oblomki })
+	case SortByPiastry:
+		sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Piastry > profiles[j].Piastry })
+	}
+	return profiles, nil
+}
+
+func (s *MemoryStorage) SaveProfile(ctx context.Context, profile models.UserProfile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile = copyProfile(profile)
+	i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == profile.TelegramID })
+	if i < 0 {
+		if profile.ID.IsZero() {
+			profile.ID = primitive.NewObjectID()
+		}
+		s.profiles = append(s.profiles, profile)
+		return nil
+	}
+	profile.ID = s.profiles[i].ID
+	s.profiles[i] = profile
+	return nil
+}
+
+func (s *MemoryStorage) SetProfileFields(ctx context.Context, telegramID int64, fields map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+	if i < 0 {
+		return ErrNotFound
+	}
+	// Поля задаются по bson-именам, поэтому применяем их через промежуточный документ.
+	data, err := bson.Marshal(s.profiles[i])
+	if err != nil {
+		return err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		setPath(doc, k, v)
+	}
+	if data, err = bson.Marshal(doc); err != nil {
+		return err
+	}
+	var updated models.UserProfile
+	if err := bson.Unmarshal(data, &updated); err != nil {
+		return err
+	}
+	s.profiles[i] = updated
+	return nil
+}
+
+// setPath присваивает значение полю документа по bson-пути с точками, как $set в MongoDB.
+func setPath(doc bson.M, path string, value interface{}) {
+	key, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		doc[key] = value
+		return
+	}
+	sub, ok := doc[key].(bson.M)
+	if !ok {
+		sub = bson.M{}
+		doc[key] = sub
+	}
+	setPath(sub, rest, value)
+}
+
+func (s *MemoryStorage) IncrementBalance(ctx context.Context, telegramID int64, deltas map[string]int) (*models.UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	p := &s.profiles[i]
+	for field, delta := range deltas {
+		p.AddBalance(field, delta)
+	}
+	profile := copyProfile(*p)
+	return &profile, nil
+}
+
+func (s *MemoryStorage) DebitBalance(ctx context.Context, telegramID int64, resource string, amount int) (*models.UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	if s.profiles[i].Balance(resource) < amount {
+		return nil, ErrInsufficientFunds
+	}
+	s.profiles[i].AddBalance(resource, -amount)
+	profile := copyProfile(s.profiles[i])
+	s.ledger = append(s.ledger, newDebitEntry(&profile, resource, amount))
+	return &profile, nil
+}
+
+func (s *MemoryStorage) DeleteProfile(ctx context.Context, telegramID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.profiles = append(s.profiles[:i], s.profiles[i+1:]...)
+	return nil
+}
+
+func (s *MemoryStorage) AddRole(ctx context.Context, telegramID int64, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+	if i < 0 {
+		return ErrNotFound
+	}
+	if !s.profiles[i].HasRole(role) {
+		s.profiles[i].Roles = append(append([]string(nil), s.profiles[i].Roles...), role)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) RemoveRole(ctx context.Context, telegramID int64, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+	if i < 0 {
+		return ErrNotFound
+	}
+	var roles []string
+	for _, r := range s.profiles[i].Roles {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	s.profiles[i].Roles = roles
+	return nil
+}
+
+func (s *MemoryStorage) AddLog(ctx context.Context, entry models.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	s.logs = append(s.logs, entry)
+	return nil
+}
+
+func (s *MemoryStorage) FindLogs(ctx context.Context, filter LogFilter) ([]models.LogEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []models.LogEntry
+	for i := len(s.logs) - 1; i >= 0; i-- {
+		if filter.Match(s.logs[i]) {
+			entries = append(entries, s.logs[i])
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.After(entries[j].Date) })
+	return entries, nil
+}
+
+func (s *MemoryStorage) CreateEvent(ctx context.Context, event *models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func (s *MemoryStorage) GetEvent(ctx context.Context, id primitive.ObjectID) (*models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range s.events {
+		if event.ID == id {
+			e := event
+			return &e, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStorage) ListEvents(ctx context.Context, status string) ([]models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []models.Event
+	for _, event := range s.events {
+		if event.Status == status {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].StartDate.After(events[j].StartDate) })
+	return events, nil
+}
+
+func (s *MemoryStorage) SetEventAnnouncement(ctx context.Context, id primitive.ObjectID, ref models.MessageRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.events {
+		if s.events[i].ID == id {
+			s.events[i].Announcement = ref
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStorage) OpenEvent(ctx context.Context, id primitive.ObjectID) (*models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.events {
+		if s.events[i].ID != id {
+			continue
+		}
+		if s.events[i].Status != models.EventScheduled {
+			return nil, ErrEventStarted
+		}
+		s.events[i].Status = models.EventActive
+		event := s.events[i]
+		return &event, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStorage) MarkEventReminded(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.events {
+		if s.events[i].ID == id {
+			if s.events[i].ReminderSent {
+				return false, nil
+			}
+			s.events[i].ReminderSent = true
+			return true, nil
+		}
+	}
+	return false, ErrNotFound
+}
+
+func (s *MemoryStorage) CloseEvent(ctx context.Context, id primitive.ObjectID, closedBy int64) (*models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.events {
+		if s.events[i].ID != id {
+			continue
+		}
+		if s.events[i].Status == models.EventClosed {
+			return nil, ErrEventClosed
+		}
+		s.events[i].Status = models.EventClosed
+		s.events[i].ClosedAt = time.Now()
+		s.events[i].ClosedBy = closedBy
+		event := s.events[i]
+		return &event, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStorage) RespondToEvent(ctx context.Context, event *models.Event, profile *models.UserProfile, choice string) (*models.EventParticipant, *models.UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.ID == event.ID && e.Status != models.EventActive {
+			return nil, nil, ErrEventClosed
+		}
+	}
+	pi := -1
+	for i := range s.participants {
+		if s.participants[i].EventID == event.ID && s.participants[i].TelegramID == profile.TelegramID {
+			pi = i
+			break
+		}
+	}
+	if pi >= 0 {
+		if existing := s.participants[pi]; existing.Choice == models.ChoiceParticipate || existing.Choice == choice {
+			return &existing, nil, ErrAlreadyResponded
+		}
+	}
+	var updated *models.UserProfile
+	if choice == models.ChoiceParticipate {
+		i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == profile.TelegramID })
+		if i < 0 {
+			return nil, nil, ErrNotFound
+		}
+		s.profiles[i].AddBalance(models.ResourceOblomki, event.Oblomki)
+		s.profiles[i].AddBalance(models.ResourcePiastry, event.Piastry)
+		p := copyProfile(s.profiles[i])
+		updated = &p
+		entries, logs := eventRewardRecords(event, updated)
+		s.ledger = append(s.ledger, entries...)
+		for _, entry := range logs {
+			entry.ID = primitive.NewObjectID()
+			s.logs = append(s.logs, entry)
+		}
+	}
+	participant := models.EventParticipant{
+		EventID:     event.ID,
+		TelegramID:  profile.TelegramID,
+		Username:    profile.Username,
+		Name:        profile.Name,
+		Choice:      choice,
+		RespondedAt: time.Now(),
+	}
+	if pi >= 0 {
+		participant.ID = s.participants[pi].ID
+		s.participants[pi] = participant
+	} else {
+		participant.ID = primitive.NewObjectID()
+		s.participants = append(s.participants, participant)
+	}
+	return &participant, updated, nil
+}
+
+func (s *MemoryStorage) ListParticipants(ctx context.Context, eventID primitive.ObjectID) ([]models.EventParticipant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var participants []models.EventParticipant
+	for _, p := range s.participants {
+		if p.EventID == eventID {
+			participants = append(participants, p)
+		}
+	}
+	sort.SliceStable(participants, func(i, j int) bool { return participants[i].RespondedAt.Before(participants[j].RespondedAt) })
+	return participants, nil
+}
+
+func (s *MemoryStorage) LastUpdateID(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastID, nil
+}
+
+func (s *MemoryStorage) SaveLastUpdateID(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID = id
+	return nil
+}
+
+func (s *MemoryStorage) GetConversation(ctx context.Context, telegramID int64) (*models.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.conversations[telegramID]
+	if !ok || !conv.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	conv.Values = copyValues(conv.Values)
+	return &conv, nil
+}
+
+func (s *MemoryStorage) SaveConversation(ctx context.Context, conv models.Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv.Values = copyValues(conv.Values)
+	s.conversations[conv.TelegramID] = conv
+	return nil
+}
+
+// copyValues копирует ответы диалога или дополнительные поля анкеты, чтобы вызывающий не менял сохранённые данные.
+func copyValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	c := make(map[string]string, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
+
+func (s *MemoryStorage) DeleteConversation(ctx context.Context, telegramID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conversations, telegramID)
+	return nil
+}
+
+func (s *MemoryStorage) ClearConversations(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.conversations)
+	s.conversations = make(map[int64]models.Conversation)
+	return n, nil
+}
+
+func (s *MemoryStorage) Transfer(ctx context.Context, from, to int64, resource string, amount int) (*models.UserProfile, *models.UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fi := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == from })
+	ti := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == to })
+	if fi < 0 || ti < 0 {
+		return nil, nil, ErrNotFound
+	}
+	if s.profiles[fi].Balance(resource) < amount {
+		return nil, nil, ErrInsufficientFunds
+	}
+	s.profiles[fi].AddBalance(resource, -amount)
+	s.profiles[ti].AddBalance(resource, amount)
+	donor, recipient := copyProfile(s.profiles[fi]), copyProfile(s.profiles[ti])
+	s.ledger = append(s.ledger, newTransferEntry(&donor, &recipient, resource, amount))
+	return &donor, &recipient, nil
+}
+
+// grantIndex возвращает индекс заявки с указанным идентификатором или -1.
+func (s *MemoryStorage) grantIndex(id primitive.ObjectID) int {
+	for i := range s.grants {
+		if s.grants[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStorage) CreateGrantRequest(ctx context.Context, request *models.GrantRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if request.ID.IsZero() {
+		request.ID = primitive.NewObjectID()
+	}
+	s.grants = append(s.grants, *request)
+	return nil
+}
+
+func (s *MemoryStorage) GetGrantRequest(ctx context.Context, id primitive.ObjectID) (*models.GrantRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.grantIndex(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	request := s.grants[i]
+	return &request, nil
+}
+
+func (s *MemoryStorage) ListGrantRequests(ctx context.Context, telegramID int64, status string) ([]models.GrantRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var requests []models.GrantRequest
+	for i := len(s.grants) - 1; i >= 0; i-- {
+		if s.grants[i].TelegramID == telegramID && s.grants[i].Status == status {
+			requests = append(requests, s.grants[i])
+		}
+	}
+	return requests, nil
+}
+
+func (s *MemoryStorage) SetGrantNotifications(ctx context.Context, id primitive.ObjectID, refs []models.MessageRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.grantIndex(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.grants[i].Notifications = append([]models.MessageRef(nil), refs...)
+	return nil
+}
+
+func (s *MemoryStorage) DecideGrantRequest(ctx context.Context, id primitive.ObjectID, status string, adminID int64) (*models.GrantRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.grantIndex(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	if s.grants[i].Status != models.GrantPending {
+		return nil, ErrAlreadyDecided
+	}
+	s.grants[i].Status = status
+	s.grants[i].DecidedBy = adminID
+	s.grants[i].DecidedAt = time.Now()
+	request := s.grants[i]
+	return &request, nil
+}
+
+func (s *MemoryStorage) ApproveGrantRequest(ctx context.Context, id primitive.ObjectID, adminID int64) (*models.GrantRequest, *models.UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gi := s.grantIndex(id)
+	if gi < 0 {
+		return nil, nil, ErrNotFound
+	}
+	if s.grants[gi].Status != models.GrantPending {
+		return nil, nil, ErrAlreadyDecided
+	}
+	pi := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == s.grants[gi].TelegramID })
+	if pi < 0 {
+		return nil, nil, ErrNotFound
+	}
+	g := &s.grants[gi]
+	g.Status = models.GrantApproved
+	g.DecidedBy = adminID
+	g.DecidedAt = time.Now()
+	s.profiles[pi].AddBalance(g.Resource, g.Amount)
+	request, profile := *g, copyProfile(s.profiles[pi])
+	s.ledger = append(s.ledger, newGrantEntry(&request, &profile))
+	return &request, &profile, nil
+}
+
+func (s *MemoryStorage) AddAudit(ctx context.Context, entry models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+func (s *MemoryStorage) FindAudit(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []models.AuditEntry
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		entry := s.audit[i]
+		if filter.ActorUsername != "" && entry.ActorUsername != filter.ActorUsername {
+			continue
+		}
+		if filter.Target != "" && entry.Target != filter.Target {
+			continue
+		}
+		if !filter.Since.IsZero() && entry.Date.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !entry.Date.Before(filter.Until) {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// itemIndex возвращает индекс предмета, удовлетворяющего условию, или -1.
+func (s *MemoryStorage) itemIndex(match func(item *models.Item) bool) int {
+	for i := range s.items {
+		if match(&s.items[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStorage) CreateItem(ctx context.Context, item *models.Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.itemIndex(func(it *models.Item) bool { return it.Key == item.Key }) >= 0 {
+		return ErrItemExists
+	}
+	item.ID = primitive.NewObjectID()
+	s.items = append(s.items, *item)
+	return nil
+}
+
+func (s *MemoryStorage) GetItem(ctx context.Context, id primitive.ObjectID) (*models.Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.itemIndex(func(it *models.Item) bool { return it.ID == id })
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	item := s.items[i]
+	return &item, nil
+}
+
+func (s *MemoryStorage) GetItemByKey(ctx context.Context, key string) (*models.Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.itemIndex(func(it *models.Item) bool { return it.Key == key })
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	item := s.items[i]
+	return &item, nil
+}
+
+func (s *MemoryStorage) ListItems(ctx context.Context) ([]models.Item, error) {
+	s.mu.Lock()
+	items := make([]models.Item, len(s.items))
+	copy(items, s.items)
+	s.mu.Unlock()
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+func (s *MemoryStorage) SetItemDescription(ctx context.Context, id primitive.ObjectID, description string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.itemIndex(func(it *models.Item) bool { return it.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.items[i].Description = description
+	return nil
+}
+
+func (s *MemoryStorage) DeleteItem(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.itemIndex(func(it *models.Item) bool { return it.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	if s.profileIndex(func(p *models.UserProfile) bool { return p.ItemQuantity(id) > 0 }) >= 0 {
+		return ErrItemInUse
+	}
+	s.items = append(s.items[:i], s.items[i+1:]...)
+	return nil
+}
+
+func (s *MemoryStorage) AdjustItem(ctx context.Context, telegramID int64, itemID primitive.ObjectID, delta int) (*models.UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	p := &s.profiles[i]
+	if p.ItemQuantity(itemID)+delta < 0 {
+		return nil, ErrInsufficientItems
+	}
+	p.AddItem(itemID, delta)
+	profile := copyProfile(*p)
+	return &profile, nil
+}
+
+// listingIndex возвращает индекс товара, удовлетворяющего условию, или -1.
+func (s *MemoryStorage) listingIndex(match func(l *models.ShopListing) bool) int {
+	for i := range s.listings {
+		if match(&s.listings[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStorage) AddListing(ctx context.Context, listing *models.ShopListing) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listingIndex(func(l *models.ShopListing) bool { return l.ItemID == listing.ItemID }) >= 0 {
+		return ErrAlreadyListed
+	}
+	listing.ID = primitive.NewObjectID()
+	s.listings = append(s.listings, *listing)
+	return nil
+}
+
+// findListing возвращает копию товара, удовлетворяющего условию.
+func (s *MemoryStorage) findListing(match func(l *models.ShopListing) bool) (*models.ShopListing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.listingIndex(match)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	listing := s.listings[i]
+	return &listing, nil
+}
+
+func (s *MemoryStorage) GetListing(ctx context.Context, id primitive.ObjectID) (*models.ShopListing, error) {
+	return s.findListing(func(l *models.ShopListing) bool { return l.ID == id })
+}
+
+func (s *MemoryStorage) GetListingByItem(ctx context.Context, itemID primitive.ObjectID) (*models.ShopListing, error) {
+	return s.findListing(func(l *models.ShopListing) bool { return l.ItemID == itemID })
+}
+
+func (s *MemoryStorage) ListListings(ctx context.Context) ([]models.ShopListing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	listings := make([]models.ShopListing, len(s.listings))
+	copy(listings, s.listings)
+	return listings, nil
+}
+
+func (s *MemoryStorage) SetListingPrice(ctx context.Context, id primitive.ObjectID, resource string, price int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.listingIndex(func(l *models.ShopListing) bool { return l.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.listings[i].Resource = resource
+	s.listings[i].Price = price
+	return nil
+}
+
+func (s *MemoryStorage) SetListingStock(ctx context.Context, id primitive.ObjectID, stock int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.listingIndex(func(l *models.ShopListing) bool { return l.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.listings[i].Stock = stock
+	return nil
+}
+
+func (s *MemoryStorage) DeleteListing(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.listingIndex(func(l *models.ShopListing) bool { return l.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.listings = append(s.listings[:i], s.listings[i+1:]...)
+	return nil
+}
+
+func (s *MemoryStorage) Buy(ctx context.Context, listingID primitive.ObjectID, telegramID int64, quantity int) (*models.UserProfile, *models.ShopListing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	li := s.listingIndex(func(l *models.ShopListing) bool { return l.ID == listingID })
+	pi := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == telegramID })
+	if li < 0 || pi < 0 {
+		return nil, nil, ErrNotFound
+	}
+	l, p := &s.listings[li], &s.profiles[pi]
+	cost := l.Price * quantity
+	if !l.InStock(quantity) {
+		return nil, nil, ErrOutOfStock
+	}
+	if p.Balance(l.Resource) < cost {
+		return nil, nil, ErrInsufficientFunds
+	}
+	if l.Stock != models.StockUnlimited {
+		l.Stock -= quantity
+	}
+	p.AddBalance(l.Resource, -cost)
+	p.AddItem(l.ItemID, quantity)
+	buyer, listing := copyProfile(*p), *l
+	s.ledger = append(s.ledger, newPurchaseEntry(&buyer, listing.Resource, cost))
+	return &buyer, &listing, nil
+}
+
+// tradeIndex возвращает индекс предложения обмена с указанным идентификатором или -1.
+func (s *MemoryStorage) tradeIndex(id primitive.ObjectID) int {
+	for i := range s.trades {
+		if s.trades[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStorage) CreateTrade(ctx context.Context, trade *models.TradeOffer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trade.ID = primitive.NewObjectID()
+	s.trades = append(s.trades, *trade)
+	return nil
+}
+
+func (s *MemoryStorage) GetTrade(ctx context.Context, id primitive.ObjectID) (*models.TradeOffer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.tradeIndex(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	trade := s.trades[i]
+	return &trade, nil
+}
+
+func (s *MemoryStorage) SetTradeMessages(ctx context.Context, id primitive.ObjectID, offer, notice models.MessageRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.tradeIndex(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.trades[i].Offer = offer
+	s.trades[i].Notice = notice
+	return nil
+}
+
+func (s *MemoryStorage) DecideTrade(ctx context.Context, id primitive.ObjectID, status string) (*models.TradeOffer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.tradeIndex(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	if s.trades[i].Status != models.TradePending {
+		return nil, ErrAlreadyDecided
+	}
+	s.trades[i].Status = status
+	s.trades[i].DecidedAt = time.Now()
+	trade := s.trades[i]
+	return &trade, nil
+}
+
+func (s *MemoryStorage) ExpireTrades(ctx context.Context, now time.Time) ([]models.TradeOffer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []models.TradeOffer
+	for i := range s.trades {
+		trade := &s.trades[i]
+		if trade.Status == models.TradePending && !trade.ExpiresAt.After(now) {
+			trade.Status = models.TradeExpired
+			trade.DecidedAt = now
+			expired = append(expired, *trade)
+		}
+	}
+	return expired, nil
+}
+
+// giveGoods переносит goods из анкеты from в анкету to, если у from всё есть.
+func giveGoods(from, to *models.UserProfile, goods models.TradeGoods) error {
+	if from.Oblomki < goods.Oblomki || from.Piastry < goods.Piastry {
+		return fmt.Errorf("%w у @%s", ErrInsufficientFunds, from.Username)
+	}
+	if !goods.HeldBy(from) {
+		return fmt.Errorf("%w у @%s", ErrInsufficientItems, from.Username)
+	}
+	for _, resource := range []string{models.ResourceOblomki, models.ResourcePiastry} {
+		from.AddBalance(resource, -goods.Amount(resource))
+		to.AddBalance(resource, goods.Amount(resource))
+	}
+	for _, item := range goods.Items {
+		from.AddItem(item.ItemID, -item.Quantity)
+		to.AddItem(item.ItemID, item.Quantity)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) ExecuteTrade(ctx context.Context, id primitive.ObjectID, now time.Time) (*models.TradeOffer, *models.UserProfile, *models.UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.tradeIndex(id)
+	if i < 0 {
+		return nil, nil, nil, ErrNotFound
+	}
+	trade := &s.trades[i]
+	if trade.Status != models.TradePending {
+		return nil, nil, nil, ErrAlreadyDecided
+	}
+	if !trade.ExpiresAt.After(now) {
+		return nil, nil, nil, ErrTradeExpired
+	}
+	fi := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == trade.From.TelegramID })
+	ti := s.profileIndex(func(p *models.UserProfile) bool { return p.TelegramID == trade.To.TelegramID })
+	if fi < 0 || ti < 0 {
+		return nil, nil, nil, ErrNotFound
+	}
+	// Изменения применяются к копиям и сохраняются, только если обе стороны смогли отдать своё.
+	from, to := copyProfile(s.profiles[fi]), copyProfile(s.profiles[ti])
+	if err := giveGoods(&from, &to, trade.Give); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := giveGoods(&to, &from, trade.Take); err != nil {
+		return nil, nil, nil, err
+	}
+	s.profiles[fi], s.profiles[ti] = from, to
+	from, to = copyProfile(from), copyProfile(to)
+	for _, resource := range []string{models.ResourceOblomki, models.ResourcePiastry} {
+		if amount := trade.Give.Amount(resource); amount > 0 {
+			s.ledger = append(s.ledger, newMovementEntry(models.LedgerTrade, &from, &to, resource, amount))
+		}
+		if amount := trade.Take.Amount(resource); amount > 0 {
+			s.ledger = append(s.ledger, newMovementEntry(models.LedgerTrade, &to, &from, resource, amount))
+		}
+	}
+	trade.Status = models.TradeAccepted
+	trade.DecidedAt = now
+	accepted := *trade
+	return &accepted, &from, &to, nil
+}
+
+func (s *MemoryStorage) CreateField(ctx context.Context, field *models.ProfileField) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.fields {
+		if f.Key == field.Key {
+			return ErrFieldExists
+		}
+	}
+	field.ID = primitive.NewObjectID()
+	s.fields = append(s.fields, *field)
+	return nil
+}
+
+func (s *MemoryStorage) GetFieldByKey(ctx context.Context, key string) (*models.ProfileField, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.fields {
+		if f.Key == key {
+			return &f, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStorage) ListFields(ctx context.Context) ([]models.ProfileField, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fields := make([]models.ProfileField, len(s.fields))
+	copy(fields, s.fields)
+	return fields, nil
+}
+
+func (s *MemoryStorage) SetFieldFlags(ctx context.Context, id primitive.ObjectID, required, editable bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.fields {
+		if s.fields[i].ID == id {
+			s.fields[i].Required = required
+			s.fields[i].Editable = editable
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStorage) DeleteField(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.fields {
+		if s.fields[i].ID != id {
+			continue
+		}
+		s.fields = append(s.fields[:i], s.fields[i+1:]...)
+		// Карта полей заменяется, а не меняется на месте, как и срезы ролей и инвентаря.
+		for j := range s.profiles {
+			if _, ok := s.profiles[j].Fields[id.Hex()]; ok {
+				fields := copyValues(s.profiles[j].Fields)
+				delete(fields, id.Hex())
+				s.profiles[j].Fields = fields
+			}
+		}
+		return nil
+	}
+	return ErrNotFound
+}
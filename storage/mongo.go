@@ -0,0 +1,1369 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"telegram-bot-go/models"
+)
+
+var _ Storage = (*MongoStorage)(nil)
+
+// MongoStorage реализует Storage поверх MongoDB.
+type MongoStorage struct {
+	db            *mongo.Database
+	users         *mongo.Collection
+	logs          *mongo.Collection
+	events        *mongo.Collection
+	state         *mongo.Collection
+	ledger        *mongo.Collection
+	grants        *mongo.Collection
+	participants  *mongo.Collection
+	audit         *mongo.Collection
+	items         *mongo.Collection
+	listings      *mongo.Collection
+	trades        *mongo.Collection
+	conversations *mongo.Collection
+	fields        *mongo.Collection
+}
+
+// NewMongo инициализирует коллекции (users, logs, events, event_participants, bot_state, ledger, grant_requests,
+// audit, items, shop, trades, conversations, profile_fields), создает индексы и переносит данные устаревших форматов.
+func NewMongo(ctx context.Context, database *mongo.Database) (*MongoStorage, error) {
+	s := &MongoStorage{
+		db:            database,
+		users:         database.Collection("users"),
+		logs:          database.Collection("logs"),
+		events:        database.Collection("events"),
+		state:         database.Collection("bot_state"),
+		ledger:        database.Collection("ledger"),
+		grants:        database.Collection("grant_requests"),
+		participants:  database.Collection("event_participants"),
+		audit:         database.Collection("audit"),
+		items:         database.Collection("items"),
+		listings:      database.Collection("shop"),
+		trades:        database.Collection("trades"),
+		conversations: database.Collection("conversations"),
+		fields:        database.Collection("profile_fields"),
+	}
+
+	// Создаем TTL-индекс для логов (удаление документов старше 30 дней = 2592000 секунд).
+	indexModel := mongo.IndexModel{
+		Keys:    bson.M{"date": 1},
+		Options: options.Index().SetExpireAfterSeconds(2592000),
+	}
+	if _, err := s.logs.Indexes().CreateOne(ctx, indexModel); err != nil {
+		return nil, err
+	}
+
+	// Один ответ на игрока в каждом ивенте.
+	participantIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "event_id", Value: 1}, {Key: "telegram_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := s.participants.Indexes().CreateOne(ctx, participantIndex); err != nil {
+		return nil, err
+	}
+
+	// Журнал действий хранится бессрочно; выборки идут по дате, исполнителю и цели.
+	auditIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "date", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_username", Value: 1}, {Key: "date", Value: -1}}},
+		{Keys: bson.D{{Key: "target", Value: 1}, {Key: "date", Value: -1}}},
+	}
+	if _, err := s.audit.Indexes().CreateMany(ctx, auditIndexes); err != nil {
+		return nil, err
+	}
+
+	// Названия предметов в каталоге уникальны без учёта регистра.
+	itemIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := s.items.Indexes().CreateOne(ctx, itemIndex); err != nil {
+		return nil, err
+	}
+
+	// Каждый предмет продаётся в магазине не больше чем одним товаром.
+	listingIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "item_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := s.listings.Indexes().CreateOne(ctx, listingIndex); err != nil {
+		return nil, err
+	}
+
+	// Планировщик ищет ожидающие предложения обмена с истёкшим сроком.
+	tradeIndex := mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}}
+	if _, err := s.trades.Indexes().CreateOne(ctx, tradeIndex); err != nil {
+		return nil, err
+	}
+
+	// Незавершённые диалоги удаляются после истечения их срока.
+	conversationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	if _, err := s.conversations.Indexes().CreateOne(ctx, conversationIndex); err != nil {
+		return nil, err
+	}
+
+	// Названия дополнительных полей анкеты уникальны без учёта регистра.
+	fieldIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := s.fields.Indexes().CreateOne(ctx, fieldIndex); err != nil {
+		return nil, err
+	}
+
+	if err := s.migrateAdminFlag(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.migrateInventoryNotes(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.migrateMeasurements(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrateAdminFlag переносит устаревший флаг is_admin в роль администратора.
+// Повторный запуск ничего не меняет: у перенесённых анкет флага уже нет.
+func (s *MongoStorage) migrateAdminFlag(ctx context.Context) error {
+	res, err := s.users.UpdateMany(ctx,
+		bson.M{"is_admin": true},
+		bson.M{"$addToSet": bson.M{"roles": models.RoleAdmin}, "$unset": bson.M{"is_admin": ""}})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount > 0 {
+		log.Printf("Миграция: флаг is_admin заменён ролью администратора у %d анкет", res.ModifiedCount)
+	}
+	// Оставшиеся флаги is_admin: false больше не нужны.
+	_, err = s.users.UpdateMany(ctx, bson.M{"is_admin": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"is_admin": ""}})
+	return err
+}
+
+// migrateInventoryNotes переносит инвентарь в свободной форме (поле inventory) в заметку inventory_note.
+// Значение по умолчанию "Пусто" не переносится. Повторный запуск ничего не меняет: поля inventory уже нет.
+func (s *MongoStorage) migrateInventoryNotes(ctx context.Context) error {
+	res, err := s.users.UpdateMany(ctx,
+		bson.M{"inventory": bson.M{"$type": "string", "$nin": bson.A{"", "Пусто"}}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"inventory_note": "$inventory"}}},
+			{{Key: "$unset", Value: "inventory"}},
+		})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount > 0 {
+		log.Printf("Миграция: инвентарь в свободной форме перенесён в заметку у %d анкет", res.ModifiedCount)
+	}
+	_, err = s.users.UpdateMany(ctx, bson.M{"inventory": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"inventory": ""}})
+	return err
+}
+
+// migrateMeasurements переводит возраст, рост и вес из строк ("30", "173.6 см\\70 кг") в числа.
+// Значения, которые не удалось разобрать, переносятся в заметки age_note и height_weight_note
+// и перечисляются в журнале. Повторный запуск ничего не меняет: строковых полей уже нет.
+func (s *MongoStorage) migrateMeasurements(ctx context.Context) error {
+	cursor, err := s.users.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"age": bson.M{"$type": "string"}},
+		bson.M{"height_weight": bson.M{"$exists": true}},
+	}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var migrated int
+	var failed []string
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID           primitive.ObjectID `bson:"_id"`
+			TelegramID   int64              `bson:"telegram_id"`
+			Username     string             `bson:"username"`
+			Age          bson.RawValue      `bson:"age"`
+			HeightWeight bson.RawValue      `bson:"height_weight"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		update, problems := measurementUpdate(doc.Age, doc.HeightWeight)
+		if _, err := s.users.UpdateOne(ctx, bson.M{"_id": doc.ID}, update); err != nil {
+			return err
+		}
+		migrated++
+		if len(problems) > 0 {
+			failed = append(failed, fmt.Sprintf("%s (Telegram ID %d, @%s): %s",
+				doc.ID.Hex(), doc.TelegramID, doc.Username, strings.Join(problems, ", ")))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if migrated > 0 {
+		log.Printf("Миграция: возраст, рост и вес переведены в числа, обработано анкет: %d", migrated)
+	}
+	if len(failed) > 0 {
+		log.Printf("Миграция: не удалось разобрать возраст, рост или вес у %d анкет, прежние значения сохранены в заметках:", len(failed))
+		for _, line := range failed {
+			log.Printf("  %s", line)
+		}
+	}
+	return nil
+}
+
+// measurementUpdate формирует обновление анкеты для migrateMeasurements по прежним строковым полям
+// age и height_weight (отсутствующее поле — нулевой RawValue) и описывает значения, которые не удалось разобрать.
+func measurementUpdate(age, heightWeight bson.RawValue) (bson.M, []string) {
+	set, unset := bson.M{}, bson.M{}
+	var problems []string
+	if age, ok := age.StringValueOK(); ok {
+		if years, parsed := models.ParseAge(age); parsed {
+			set["age"] = years
+		} else {
+			unset["age"] = ""
+			if age = strings.TrimSpace(age); age != "" {
+				set["age_note"] = age
+				problems = append(problems, fmt.Sprintf("возраст %q", age))
+			}
+		}
+	}
+	if !heightWeight.IsZero() {
+		unset["height_weight"] = ""
+		hw, _ := heightWeight.StringValueOK()
+		if height, weight, parsed := models.ParseHeightWeight(hw); parsed {
+			set["height_cm"] = height
+			set["weight_kg"] = weight
+		} else if hw = strings.TrimSpace(hw); hw != "" {
+			set["height_weight_note"] = hw
+			problems = append(problems, fmt.Sprintf("рост и вес %q", hw))
+		}
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, problems
+}
+
+// findOneProfile ищет одну анкету по фильтру.
+func (s *MongoStorage) findOneProfile(ctx context.Context, filter bson.M) (*models.UserProfile, error) {
+	var profile models.UserProfile
+	err := s.users.FindOne(ctx, filter).Decode(&profile)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (s *MongoStorage) GetProfile(ctx context.Context, telegramID int64) (*models.UserProfile, error) {
+	return s.findOneProfile(ctx, bson.M{"telegram_id": telegramID})
+}
+
+func (s *MongoStorage) GetProfileByUsername(ctx context.Context, username string) (*models.UserProfile, error) {
+	return s.findOneProfile(ctx, bson.M{"username": username})
+}
+
+func (s *MongoStorage) GetProfileByID(ctx context.Context, id primitive.ObjectID) (*models.UserProfile, error) {
+	return s.findOneProfile(ctx, bson.M{"_id": id})
+}
+
+func (s *MongoStorage) ListProfiles(ctx context.Context, sort ProfileSort) ([]models.UserProfile, error) {
+	opts := options.Find()
+	switch sort {
+	case SortByName:
+		opts.SetSort(bson.D{{Key: "name", Value: 1}})
+	case SortByOblomki:
+		opts.SetSort(bson.D{{Key: "oblomki", Value: -1}})
+	case SortByPiastry:
+		opts.SetSort(bson.D{{Key: "piastry", Value: -1}})
+	}
+	cursor, err := s.users.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var profiles []models.UserProfile
+	for cursor.Next(ctx) {
+		var profile models.UserProfile
+		if err := cursor.Decode(&profile); err != nil {
+			continue
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, cursor.Err()
+}
+
+func (s *MongoStorage) SaveProfile(ctx context.Context, profile models.UserProfile) error {
+	filter := bson.M{"telegram_id": profile.TelegramID}
+	_, err := s.users.ReplaceOne(ctx, filter, profile, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStorage) SetProfileFields(ctx context.Context, telegramID int64, fields map[string]interface{}) error {
+	res, err := s.users.UpdateOne(ctx, bson.M{"telegram_id": telegramID}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStorage) IncrementBalance(ctx context.Context, telegramID int64, deltas map[string]int) (*models.UserProfile, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var profile models.UserProfile
+	err := s.users.FindOneAndUpdate(ctx, bson.M{"telegram_id": telegramID}, bson.M{"$inc": deltas}, opts).Decode(&profile)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// DebitBalance выполняется в транзакции, поэтому MongoDB должна быть запущена как replica set.
+func (s *MongoStorage) DebitBalance(ctx context.Context, telegramID int64, resource string, amount int) (*models.UserProfile, error) {
+	session, err := s.db.Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var profile models.UserProfile
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		// Условие на баланс проверяется атомарно вместе с $inc, как при передаче.
+		filter := bson.M{"telegram_id": telegramID, resource: bson.M{"$gte": amount}}
+		err := s.users.FindOneAndUpdate(sc, filter, bson.M{"$inc": bson.M{resource: -amount}}, opts).Decode(&profile)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if _, err := s.GetProfile(sc, telegramID); err != nil {
+				return nil, err
+			}
+			return nil, ErrInsufficientFunds
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.ledger.InsertOne(sc, newDebitEntry(&profile, resource, amount))
+		return nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (s *MongoStorage) DeleteProfile(ctx context.Context, telegramID int64) error {
+	res, err := s.users.DeleteOne(ctx, bson.M{"telegram_id": telegramID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStorage) AddRole(ctx context.Context, telegramID int64, role string) error {
+	res, err := s.users.UpdateOne(ctx, bson.M{"telegram_id": telegramID}, bson.M{"$addToSet": bson.M{"roles": role}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStorage) RemoveRole(ctx context.Context, telegramID int64, role string) error {
+	res, err := s.users.UpdateOne(ctx, bson.M{"telegram_id": telegramID}, bson.M{"$pull": bson.M{"roles": role}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStorage) AddLog(ctx context.Context, entry models.LogEntry) error {
+	_, err := s.logs.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoStorage) FindLogs(ctx context.Context, filter LogFilter) ([]models.LogEntry, error) {
+	query := bson.M{}
+	if filter.TelegramID != 0 {
+		query["telegram_id"] = filter.TelegramID
+	}
+	if filter.Resource != "" {
+		// Ресурс в логах записан так, как его ввёл пользователь, поэтому сравниваем без учёта регистра.
+		query["resource"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(filter.Resource) + "$", Options: "i"}
+	}
+	switch {
+	case filter.Sign > 0:
+		query["change_amount"] = bson.M{"$gt": 0}
+	case filter.Sign < 0:
+		query["change_amount"] = bson.M{"$lt": 0}
+	}
+	date := bson.M{}
+	if !filter.Since.IsZero() {
+		date["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		date["$lt"] = filter.Until
+	}
+	if len(date) > 0 {
+		query["date"] = date
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}})
+	cursor, err := s.logs.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.LogEntry
+	for cursor.Next(ctx) {
+		var entry models.LogEntry
+		if err := cursor.Decode(&entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, cursor.Err()
+}
+
+func (s *MongoStorage) CreateEvent(ctx context.Context, event *models.Event) error {
+	res, err := s.events.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		event.ID = id
+	}
+	return nil
+}
+
+func (s *MongoStorage) GetEvent(ctx context.Context, id primitive.ObjectID) (*models.Event, error) {
+	var event models.Event
+	err := s.events.FindOne(ctx, bson.M{"_id": id}).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *MongoStorage) ListEvents(ctx context.Context, status string) ([]models.Event, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "start_date", Value: -1}})
+	cursor, err := s.events.Find(ctx, bson.M{"status": status}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []models.Event
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *MongoStorage) SetEventAnnouncement(ctx context.Context, id primitive.ObjectID, ref models.MessageRef) error {
+	res, err := s.events.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"announcement": ref}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStorage) OpenEvent(ctx context.Context, id primitive.ObjectID) (*models.Event, error) {
+	filter := bson.M{"_id": id, "status": models.EventScheduled}
+	update := bson.M{"$set": bson.M{"status": models.EventActive}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var event models.Event
+	err := s.events.FindOneAndUpdate(ctx, filter, update, opts).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, err := s.GetEvent(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrEventStarted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *MongoStorage) MarkEventReminded(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	res, err := s.events.UpdateOne(ctx,
+		bson.M{"_id": id, "reminder_sent": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"reminder_sent": true}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (s *MongoStorage) CloseEvent(ctx context.Context, id primitive.ObjectID, closedBy int64) (*models.Event, error) {
+	// Статус меняется только у незавершённого ивента, поэтому команда и автозавершение не закроют его дважды.
+	filter := bson.M{"_id": id, "status": bson.M{"$in": []string{models.EventActive, models.EventScheduled}}}
+	update := bson.M{"$set": bson.M{"status": models.EventClosed, "closed_at": time.Now(), "closed_by": closedBy}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var event models.Event
+	err := s.events.FindOneAndUpdate(ctx, filter, update, opts).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, err := s.GetEvent(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrEventClosed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// RespondToEvent выполняется в транзакции, поэтому MongoDB должна быть запущена как replica set.
+func (s *MongoStorage) RespondToEvent(ctx context.Context, event *models.Event, profile *models.UserProfile, choice string) (*models.EventParticipant, *models.UserProfile, error) {
+	session, err := s.db.Client().StartSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	key := bson.M{"event_id": event.ID, "telegram_id": profile.TelegramID}
+	var participant models.EventParticipant
+	var updated *models.UserProfile
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		updated = nil
+		// Проверка статуса внутри транзакции не даёт ответить на ивент, завершённый параллельно.
+		if err := s.events.FindOne(sc, bson.M{"_id": event.ID, "status": models.EventActive}).Err(); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrEventClosed
+			}
+			return nil, err
+		}
+		// Запись обновляется, только если прежний ответ — не участие и не тот же самый.
+		// Иначе upsert пытается вставить дубликат и упирается в уникальный индекс.
+		filter := bson.M{
+			"event_id":    event.ID,
+			"telegram_id": profile.TelegramID,
+			"choice":      bson.M{"$nin": []string{models.ChoiceParticipate, choice}},
+		}
+		update := bson.M{"$set": bson.M{
+			"username":     profile.Username,
+			"name":         profile.Name,
+			"choice":       choice,
+			"responded_at": time.Now(),
+		}}
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+		err := s.participants.FindOneAndUpdate(sc, filter, update, opts).Decode(&participant)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrAlreadyResponded
+		}
+		if err != nil {
+			return nil, err
+		}
+		if choice != models.ChoiceParticipate {
+			return nil, nil
+		}
+		var p models.UserProfile
+		inc := bson.M{models.ResourceOblomki: event.Oblomki, models.ResourcePiastry: event.Piastry}
+		err = s.users.FindOneAndUpdate(sc, bson.M{"telegram_id": profile.TelegramID}, bson.M{"$inc": inc},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&p)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Награда записывается в реестр и журнал той же транзакцией, что и начисление.
+		entries, logs := eventRewardRecords(event, &p)
+		for _, entry := range entries {
+			if _, err := s.ledger.InsertOne(sc, entry); err != nil {
+				return nil, err
+			}
+		}
+		for _, entry := range logs {
+			if _, err := s.logs.InsertOne(sc, entry); err != nil {
+				return nil, err
+			}
+		}
+		updated = &p
+		return nil, nil
+	})
+	if errors.Is(err, ErrAlreadyResponded) {
+		if err := s.participants.FindOne(ctx, key).Decode(&participant); err != nil {
+			return nil, nil, err
+		}
+		return &participant, nil, ErrAlreadyResponded
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &participant, updated, nil
+}
+
+func (s *MongoStorage) ListParticipants(ctx context.Context, eventID primitive.ObjectID) ([]models.EventParticipant, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "responded_at", Value: 1}})
+	cursor, err := s.participants.Find(ctx, bson.M{"event_id": eventID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var participants []models.EventParticipant
+	if err := cursor.All(ctx, &participants); err != nil {
+		return nil, err
+	}
+	return participants, nil
+}
+
+// pollingStateID — идентификатор документа с состоянием long polling в коллекции bot_state.
+const pollingStateID = "polling"
+
+func (s *MongoStorage) LastUpdateID(ctx context.Context) (int, error) {
+	var doc struct {
+		LastUpdateID int `bson:"last_update_id"`
+	}
+	err := s.state.FindOne(ctx, bson.M{"_id": pollingStateID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return doc.LastUpdateID, nil
+}
+
+func (s *MongoStorage) SaveLastUpdateID(ctx context.Context, id int) error {
+	_, err := s.state.UpdateOne(ctx,
+		bson.M{"_id": pollingStateID},
+		bson.M{"$set": bson.M{"last_update_id": id, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// GetConversation проверяет срок диалога сам: MongoDB удаляет просроченные документы не сразу, а раз в минуту.
+func (s *MongoStorage) GetConversation(ctx context.Context, telegramID int64) (*models.Conversation, error) {
+	var conv models.Conversation
+	err := s.conversations.FindOne(ctx, bson.M{"_id": telegramID, "expires_at": bson.M{"$gt": time.Now()}}).Decode(&conv)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+func (s *MongoStorage) SaveConversation(ctx context.Context, conv models.Conversation) error {
+	_, err := s.conversations.ReplaceOne(ctx, bson.M{"_id": conv.TelegramID}, conv, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStorage) DeleteConversation(ctx context.Context, telegramID int64) error {
+	_, err := s.conversations.DeleteOne(ctx, bson.M{"_id": telegramID})
+	return err
+}
+
+func (s *MongoStorage) ClearConversations(ctx context.Context) (int, error) {
+	res, err := s.conversations.DeleteMany(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+// Transfer выполняется в транзакции, поэтому MongoDB должна быть запущена как replica set.
+func (s *MongoStorage) Transfer(ctx context.Context, from, to int64, resource string, amount int) (*models.UserProfile, *models.UserProfile, error) {
+	session, err := s.db.Client().StartSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var donor, recipient models.UserProfile
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		// Списание только при достаточном балансе: условие проверяется атомарно вместе с $inc.
+		debitFilter := bson.M{"telegram_id": from, resource: bson.M{"$gte": amount}}
+		err := s.users.FindOneAndUpdate(sc, debitFilter, bson.M{"$inc": bson.M{resource: -amount}}, opts).Decode(&donor)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if _, err := s.findOneProfile(sc, bson.M{"telegram_id": from}); err != nil {
+				return nil, err
+			}
+			return nil, ErrInsufficientFunds
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = s.users.FindOneAndUpdate(sc, bson.M{"telegram_id": to}, bson.M{"$inc": bson.M{resource: amount}}, opts).Decode(&recipient)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry := newTransferEntry(&donor, &recipient, resource, amount)
+		_, err = s.ledger.InsertOne(sc, entry)
+		return nil, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &donor, &recipient, nil
+}
+
+func (s *MongoStorage) CreateGrantRequest(ctx context.Context, request *models.GrantRequest) error {
+	res, err := s.grants.InsertOne(ctx, request)
+	if err != nil {
+		return err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		request.ID = id
+	}
+	return nil
+}
+
+func (s *MongoStorage) GetGrantRequest(ctx context.Context, id primitive.ObjectID) (*models.GrantRequest, error) {
+	var request models.GrantRequest
+	err := s.grants.FindOne(ctx, bson.M{"_id": id}).Decode(&request)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (s *MongoStorage) ListGrantRequests(ctx context.Context, telegramID int64, status string) ([]models.GrantRequest, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := s.grants.Find(ctx, bson.M{"telegram_id": telegramID, "status": status}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []models.GrantRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (s *MongoStorage) SetGrantNotifications(ctx context.Context, id primitive.ObjectID, refs []models.MessageRef) error {
+	_, err := s.grants.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"notifications": refs}})
+	return err
+}
+
+func (s *MongoStorage) DecideGrantRequest(ctx context.Context, id primitive.ObjectID, status string, adminID int64) (*models.GrantRequest, error) {
+	// Статус меняется только у заявки в ожидании, поэтому два администратора не могут одобрить её дважды.
+	filter := bson.M{"_id": id, "status": models.GrantPending}
+	update := bson.M{"$set": bson.M{"status": status, "decided_by": adminID, "decided_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var request models.GrantRequest
+	err := s.grants.FindOneAndUpdate(ctx, filter, update, opts).Decode(&request)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, err := s.GetGrantRequest(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAlreadyDecided
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ApproveGrantRequest выполняется в транзакции, поэтому MongoDB должна быть запущена как replica set.
+func (s *MongoStorage) ApproveGrantRequest(ctx context.Context, id primitive.ObjectID, adminID int64) (*models.GrantRequest, *models.UserProfile, error) {
+	session, err := s.db.Client().StartSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var request *models.GrantRequest
+	var profile models.UserProfile
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		var err error
+		if request, err = s.DecideGrantRequest(sc, id, models.GrantApproved, adminID); err != nil {
+			return nil, err
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		inc := bson.M{"$inc": bson.M{request.Resource: request.Amount}}
+		err = s.users.FindOneAndUpdate(sc, bson.M{"telegram_id": request.TelegramID}, inc, opts).Decode(&profile)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.ledger.InsertOne(sc, newGrantEntry(request, &profile))
+		return nil, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return request, &profile, nil
+}
+
+func (s *MongoStorage) AddAudit(ctx context.Context, entry models.AuditEntry) error {
+	_, err := s.audit.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoStorage) FindAudit(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	query := bson.M{}
+	if filter.ActorUsername != "" {
+		query["actor_username"] = filter.ActorUsername
+	}
+	if filter.Target != "" {
+		query["target"] = filter.Target
+	}
+	date := bson.M{}
+	if !filter.Since.IsZero() {
+		date["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		date["$lt"] = filter.Until
+	}
+	if len(date) > 0 {
+		query["date"] = date
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := s.audit.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.AuditEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *MongoStorage) CreateItem(ctx context.Context, item *models.Item) error {
+	res, err := s.items.InsertOne(ctx, item)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrItemExists
+	}
+	if err != nil {
+		return err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		item.ID = id
+	}
+	return nil
+}
+
+// findOneItem ищет один предмет каталога по фильтру.
+func (s *MongoStorage) findOneItem(ctx context.Context, filter bson.M) (*models.Item, error) {
+	var item models.Item
+	err := s.items.FindOne(ctx, filter).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *MongoStorage) GetItem(ctx context.Context, id primitive.ObjectID) (*models.Item, error) {
+	return s.findOneItem(ctx, bson.M{"_id": id})
+}
+
+func (s *MongoStorage) GetItemByKey(ctx context.Context, key string) (*models.Item, error) {
+	return s.findOneItem(ctx, bson.M{"key": key})
+}
+
+func (s *MongoStorage) ListItems(ctx context.Context) ([]models.Item, error) {
+	cursor, err := s.items.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var items []models.Item
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *MongoStorage) SetItemDescription(ctx context.Context, id primitive.ObjectID, description string) error {
+	res, err := s.items.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"description": description}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStorage) DeleteItem(ctx context.Context, id primitive.ObjectID) error {
+	holders, err := s.users.CountDocuments(ctx, bson.M{"items.item_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if holders > 0 {
+		return ErrItemInUse
+	}
+	res, err := s.items.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStorage) AdjustItem(ctx context.Context, telegramID int64, itemID primitive.ObjectID, delta int) (*models.UserProfile, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var profile models.UserProfile
+	if delta < 0 {
+		// Списание только при достаточном количестве: условие проверяется атомарно вместе с $inc.
+		filter := bson.M{"telegram_id": telegramID, "items": bson.M{"$elemMatch": bson.M{"item_id": itemID, "quantity": bson.M{"$gte": -delta}}}}
+		err := s.users.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"items.$.quantity": delta}}, opts).Decode(&profile)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if _, err := s.findOneProfile(ctx, bson.M{"telegram_id": telegramID}); err != nil {
+				return nil, err
+			}
+			return nil, ErrInsufficientItems
+		}
+		if err != nil {
+			return nil, err
+		}
+		if profile.ItemQuantity(itemID) > 0 {
+			return &profile, nil
+		}
+		// Закончившиеся предметы убираются из инвентаря.
+		pull := bson.M{"$pull": bson.M{"items": bson.M{"item_id": itemID, "quantity": bson.M{"$lte": 0}}}}
+		if err := s.users.FindOneAndUpdate(ctx, bson.M{"telegram_id": telegramID}, pull, opts).Decode(&profile); err != nil {
+			return nil, err
+		}
+		return &profile, nil
+	}
+
+	// Сначала увеличиваем количество уже имеющегося предмета, а если его нет — добавляем запись.
+	// Вторая попытка нужна, если запись добавил параллельный запрос между двумя обновлениями.
+	for attempt := 0; attempt < 2; attempt++ {
+		filter := bson.M{"telegram_id": telegramID, "items.item_id": itemID}
+		err := s.users.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"items.$.quantity": delta}}, opts).Decode(&profile)
+		if err == nil {
+			return &profile, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		filter = bson.M{"telegram_id": telegramID, "items.item_id": bson.M{"$ne": itemID}}
+		push := bson.M{"$push": bson.M{"items": models.InventoryItem{ItemID: itemID, Quantity: delta}}}
+		err = s.users.FindOneAndUpdate(ctx, filter, push, opts).Decode(&profile)
+		if err == nil {
+			return &profile, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		if _, err := s.findOneProfile(ctx, bson.M{"telegram_id": telegramID}); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.New("не удалось изменить инвентарь: параллельные изменения")
+}
+
+func (s *MongoStorage) AddListing(ctx context.Context, listing *models.ShopListing) error {
+	res, err := s.listings.InsertOne(ctx, listing)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyListed
+	}
+	if err != nil {
+		return err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		listing.ID = id
+	}
+	return nil
+}
+
+// findOneListing ищет один товар магазина по фильтру.
+func (s *MongoStorage) findOneListing(ctx context.Context, filter bson.M) (*models.ShopListing, error) {
+	var listing models.ShopListing
+	err := s.listings.FindOne(ctx, filter).Decode(&listing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+func (s *MongoStorage) GetListing(ctx context.Context, id primitive.ObjectID) (*models.ShopListing, error) {
+	return s.findOneListing(ctx, bson.M{"_id": id})
+}
+
+func (s *MongoStorage) GetListingByItem(ctx context.Context, itemID primitive.ObjectID) (*models.ShopListing, error) {
+	return s.findOneListing(ctx, bson.M{"item_id": itemID})
+}
+
+func (s *MongoStorage) ListListings(ctx context.Context) ([]models.ShopListing, error) {
+	cursor, err := s.listings.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var listings []models.ShopListing
+	if err := cursor.All(ctx, &listings); err != nil {
+		return nil, err
+	}
+	return listings, nil
+}
+
+// updateListing изменяет поля товара.
+func (s *MongoStorage) updateListing(ctx context.Context, id primitive.ObjectID, fields bson.M) error {
+	res, err := s.listings.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStorage) SetListingPrice(ctx context.Context, id primitive.ObjectID, resource string, price int) error {
+	return s.updateListing(ctx, id, bson.M{"resource": resource, "price": price})
+}
+
+func (s *MongoStorage) SetListingStock(ctx context.Context, id primitive.ObjectID, stock int) error {
+	return s.updateListing(ctx, id, bson.M{"stock": stock})
+}
+
+func (s *MongoStorage) DeleteListing(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.listings.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Buy выполняется в транзакции, поэтому MongoDB должна быть запущена как replica set.
+func (s *MongoStorage) Buy(ctx context.Context, listingID primitive.ObjectID, telegramID int64, quantity int) (*models.UserProfile, *models.ShopListing, error) {
+	session, err := s.db.Client().StartSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var buyer *models.UserProfile
+	var listing *models.ShopListing
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		var err error
+		if listing, err = s.findOneListing(sc, bson.M{"_id": listingID}); err != nil {
+			return nil, err
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		// Запас уменьшается только при достаточном остатке: условие проверяется атомарно вместе с $inc.
+		if listing.Stock != models.StockUnlimited {
+			filter := bson.M{"_id": listingID, "stock": bson.M{"$gte": quantity}}
+			err := s.listings.FindOneAndUpdate(sc, filter, bson.M{"$inc": bson.M{"stock": -quantity}}, opts).Decode(listing)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrOutOfStock
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		cost := listing.Price * quantity
+		debitFilter := bson.M{"telegram_id": telegramID, listing.Resource: bson.M{"$gte": cost}}
+		err = s.users.FindOneAndUpdate(sc, debitFilter, bson.M{"$inc": bson.M{listing.Resource: -cost}}, opts).Err()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if _, err := s.findOneProfile(sc, bson.M{"telegram_id": telegramID}); err != nil {
+				return nil, err
+			}
+			return nil, ErrInsufficientFunds
+		}
+		if err != nil {
+			return nil, err
+		}
+		if buyer, err = s.AdjustItem(sc, telegramID, listing.ItemID, quantity); err != nil {
+			return nil, err
+		}
+		_, err = s.ledger.InsertOne(sc, newPurchaseEntry(buyer, listing.Resource, cost))
+		return nil, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return buyer, listing, nil
+}
+
+func (s *MongoStorage) CreateTrade(ctx context.Context, trade *models.TradeOffer) error {
+	res, err := s.trades.InsertOne(ctx, trade)
+	if err != nil {
+		return err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		trade.ID = id
+	}
+	return nil
+}
+
+func (s *MongoStorage) GetTrade(ctx context.Context, id primitive.ObjectID) (*models.TradeOffer, error) {
+	var trade models.TradeOffer
+	err := s.trades.FindOne(ctx, bson.M{"_id": id}).Decode(&trade)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &trade, nil
+}
+
+func (s *MongoStorage) SetTradeMessages(ctx context.Context, id primitive.ObjectID, offer, notice models.MessageRef) error {
+	_, err := s.trades.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"offer": offer, "notice": notice}})
+	return err
+}
+
+func (s *MongoStorage) DecideTrade(ctx context.Context, id primitive.ObjectID, status string) (*models.TradeOffer, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var trade models.TradeOffer
+	err := s.trades.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.TradePending},
+		bson.M{"$set": bson.M{"status": status, "decided_at": time.Now()}},
+		opts).Decode(&trade)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, err := s.GetTrade(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAlreadyDecided
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &trade, nil
+}
+
+func (s *MongoStorage) ExpireTrades(ctx context.Context, now time.Time) ([]models.TradeOffer, error) {
+	cursor, err := s.trades.Find(ctx, bson.M{"status": models.TradePending, "expires_at": bson.M{"$lte": now}})
+	if err != nil {
+		return nil, err
+	}
+	var due []models.TradeOffer
+	if err := cursor.All(ctx, &due); err != nil {
+		return nil, err
+	}
+	// Каждое предложение переводится условным обновлением, чтобы не спорить с одновременным ответом игрока.
+	var expired []models.TradeOffer
+	for _, trade := range due {
+		res, err := s.trades.UpdateOne(ctx,
+			bson.M{"_id": trade.ID, "status": models.TradePending},
+			bson.M{"$set": bson.M{"status": models.TradeExpired, "decided_at": now}})
+		if err != nil {
+			return expired, err
+		}
+		if res.ModifiedCount > 0 {
+			trade.Status = models.TradeExpired
+			trade.DecidedAt = now
+			expired = append(expired, trade)
+		}
+	}
+	return expired, nil
+}
+
+// moveGoods переносит goods от одной стороны обмена к другой в рамках транзакции sc.
+// Списание каждой ценности проверяет остаток атомарно вместе с изменением.
+func (s *MongoStorage) moveGoods(sc mongo.SessionContext, from, to models.TradeParty, goods models.TradeGoods) error {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	for _, resource := range []string{models.ResourceOblomki, models.ResourcePiastry} {
+		amount := goods.Amount(resource)
+		if amount == 0 {
+			continue
+		}
+		var donor, recipient models.UserProfile
+		debitFilter := bson.M{"telegram_id": from.TelegramID, resource: bson.M{"$gte": amount}}
+		err := s.users.FindOneAndUpdate(sc, debitFilter, bson.M{"$inc": bson.M{resource: -amount}}, opts).Decode(&donor)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w у @%s", ErrInsufficientFunds, from.Username)
+		}
+		if err != nil {
+			return err
+		}
+		err = s.users.FindOneAndUpdate(sc, bson.M{"telegram_id": to.TelegramID}, bson.M{"$inc": bson.M{resource: amount}}, opts).Decode(&recipient)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.ledger.InsertOne(sc, newMovementEntry(models.LedgerTrade, &donor, &recipient, resource, amount)); err != nil {
+			return err
+		}
+	}
+	for _, item := range goods.Items {
+		_, err := s.AdjustItem(sc, from.TelegramID, item.ItemID, -item.Quantity)
+		if errors.Is(err, ErrInsufficientItems) {
+			return fmt.Errorf("%w у @%s", ErrInsufficientItems, from.Username)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.AdjustItem(sc, to.TelegramID, item.ItemID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecuteTrade выполняется в транзакции, поэтому MongoDB должна быть запущена как replica set.
+func (s *MongoStorage) ExecuteTrade(ctx context.Context, id primitive.ObjectID, now time.Time) (*models.TradeOffer, *models.UserProfile, *models.UserProfile, error) {
+	session, err := s.db.Client().StartSession()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var trade models.TradeOffer
+	var from, to *models.UserProfile
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		filter := bson.M{"_id": id, "status": models.TradePending, "expires_at": bson.M{"$gt": now}}
+		update := bson.M{"$set": bson.M{"status": models.TradeAccepted, "decided_at": now}}
+		err := s.trades.FindOneAndUpdate(sc, filter, update, opts).Decode(&trade)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			current, err := s.GetTrade(sc, id)
+			if err != nil {
+				return nil, err
+			}
+			if current.Status == models.TradePending {
+				return nil, ErrTradeExpired
+			}
+			return nil, ErrAlreadyDecided
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := s.moveGoods(sc, trade.From, trade.To, trade.Give); err != nil {
+			return nil, err
+		}
+		if err := s.moveGoods(sc, trade.To, trade.From, trade.Take); err != nil {
+			return nil, err
+		}
+		if from, err = s.findOneProfile(sc, bson.M{"telegram_id": trade.From.TelegramID}); err != nil {
+			return nil, err
+		}
+		to, err = s.findOneProfile(sc, bson.M{"telegram_id": trade.To.TelegramID})
+		return nil, err
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &trade, from, to, nil
+}
+
+func (s *MongoStorage) CreateField(ctx context.Context, field *models.ProfileField) error {
+	res, err := s.fields.InsertOne(ctx, field)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrFieldExists
+	}
+	if err != nil {
+		return err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		field.ID = id
+	}
+	return nil
+}
+
+func (s *MongoStorage) GetFieldByKey(ctx context.Context, key string) (*models.ProfileField, error) {
+	var field models.ProfileField
+	err := s.fields.FindOne(ctx, bson.M{"key": key}).Decode(&field)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+func (s *MongoStorage) ListFields(ctx context.Context) ([]models.ProfileField, error) {
+	cursor, err := s.fields.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var fields []models.ProfileField
+	if err := cursor.All(ctx, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (s *MongoStorage) SetFieldFlags(ctx context.Context, id primitive.ObjectID, required, editable bool) error {
+	res, err := s.fields.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"required": required, "editable": editable}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteField сначала удаляет само поле, затем его значения: если второй шаг прервётся,
+// оставшиеся значения без описания поля не показываются и ни на что не влияют.
+func (s *MongoStorage) DeleteField(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.fields.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	path := "fields." + id.Hex()
+	_, err = s.users.UpdateMany(ctx, bson.M{path: bson.M{"$exists": true}}, bson.M{"$unset": bson.M{path: ""}})
+	return err
+}
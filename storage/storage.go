@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"telegram-bot-go/models"
+)
+
+var (
+	// ErrNotFound возвращается, когда запрошенная запись отсутствует в хранилище.
+	ErrNotFound = errors.New("запись не найдена")
+	// ErrAlreadyDecided возвращается при попытке повторно рассмотреть заявку.
+	ErrAlreadyDecided = errors.New("заявка уже рассмотрена")
+	// ErrInsufficientFunds возвращается, когда на балансе недостаточно ресурса для списания.
+	ErrInsufficientFunds = errors.New("недостаточно средств")
+	// ErrAlreadyResponded возвращается, когда ответ игрока на ивент уже записан и не может быть изменён.
+	ErrAlreadyResponded = errors.New("ответ на ивент уже записан")
+	// ErrEventClosed возвращается при попытке ответить на завершённый ивент или завершить его повторно.
+	ErrEventClosed = errors.New("ивент уже завершён")
+	// ErrEventStarted возвращается при попытке открыть ивент, который уже не ждёт начала.
+	ErrEventStarted = errors.New("ивент уже начат")
+	// ErrItemExists возвращается при добавлении в каталог предмета с уже занятым названием.
+	ErrItemExists = errors.New("предмет с таким названием уже есть")
+	// ErrItemInUse возвращается при удалении из каталога предмета, который есть у игроков.
+	ErrItemInUse = errors.New("предмет есть в инвентаре игроков")
+	// ErrInsufficientItems возвращается, когда в инвентаре недостаточно предметов для списания.
+	ErrInsufficientItems = errors.New("недостаточно предметов")
+	// ErrAlreadyListed возвращается при выставлении в магазин предмета, который уже продаётся.
+	ErrAlreadyListed = errors.New("предмет уже продаётся")
+	// ErrOutOfStock возвращается, когда запаса товара в магазине не хватает на покупку.
+	ErrOutOfStock = errors.New("товар закончился")
+	// ErrTradeExpired возвращается при попытке принять просроченное предложение обмена.
+	ErrTradeExpired = errors.New("предложение обмена истекло")
+	// ErrFieldExists возвращается при добавлении поля анкеты с уже занятым названием.
+	ErrFieldExists = errors.New("поле анкеты с таким названием уже есть")
+)
+
+// ProfileSort задаёт порядок сортировки при выборке анкет.
+type ProfileSort int
+
+const (
+	SortNone      ProfileSort = iota // порядок добавления
+	SortByName                       // по имени, по возрастанию
+	SortByOblomki                    // по обломкам, по убыванию
+	SortByPiastry                    // по пиастрам, по убыванию
+)
+
+// Storage объединяет все хранилища, с которыми работают обработчики.
+type Storage interface {
+	Profiles
+	Logs
+	Events
+	State
+	Conversations
+	Ledger
+	Grants
+	Audit
+	Items
+	Shop
+	Trades
+	CustomFields
+}
+
+// Profiles хранит анкеты пользователей.
+type Profiles interface {
+	// GetProfile возвращает анкету по Telegram ID.
+	GetProfile(ctx context.Context, telegramID int64) (*models.UserProfile, error)
+	// GetProfileByUsername возвращает анкету по имени пользователя (в нижнем регистре).
+	GetProfileByUsername(ctx context.Context, username string) (*models.UserProfile, error)
+	// GetProfileByID возвращает анкету по её идентификатору.
+	GetProfileByID(ctx context.Context, id primitive.ObjectID) (*models.UserProfile, error)
+	// ListProfiles возвращает все анкеты в указанном порядке.
+	ListProfiles(ctx context.Context, sort ProfileSort) ([]models.UserProfile, error)
+	// SaveProfile создаёт анкету или полностью перезаписывает существующую с тем же Telegram ID.
+	SaveProfile(ctx context.Context, profile models.UserProfile) error
+	// SetProfileFields устанавливает значения полей анкеты (ключи — имена bson-полей).
+	SetProfileFields(ctx context.Context, telegramID int64, fields map[string]interface{}) error
+	// IncrementBalance изменяет ресурсы анкеты на указанные величины и возвращает обновлённую анкету.
+	IncrementBalance(ctx context.Context, telegramID int64, deltas map[string]int) (*models.UserProfile, error)
+	// DebitBalance атомарно списывает amount ресурса resource и возвращает обновлённую анкету.
+	// Списание происходит только при достаточном балансе, иначе возвращается ErrInsufficientFunds.
+	// Вместе со списанием в реестр записывается операция вида LedgerDebit.
+	DebitBalance(ctx context.Context, telegramID int64, resource string, amount int) (*models.UserProfile, error)
+	// DeleteProfile удаляет анкету.
+	DeleteProfile(ctx context.Context, telegramID int64) error
+	// AddRole назначает анкете роль (повторное назначение ничего не меняет).
+	AddRole(ctx context.Context, telegramID int64, role string) error
+	// RemoveRole снимает с анкеты роль.
+	RemoveRole(ctx context.Context, telegramID int64, role string) error
+}
+
+// Logs хранит журнал изменений ресурсов.
+type Logs interface {
+	// AddLog добавляет запись в журнал.
+	AddLog(ctx context.Context, entry models.LogEntry) error
+	// FindLogs возвращает записи журнала по фильтру, от новых к старым.
+	FindLogs(ctx context.Context, filter LogFilter) ([]models.LogEntry, error)
+}
+
+// LogFilter задаёт условия выборки из журнала изменений ресурсов. Пустые поля не ограничивают выборку.
+type LogFilter struct {
+	TelegramID int64     // только записи этого пользователя
+	Resource   string    // название ресурса (без учёта регистра), например "обломки"
+	Sign       int       // 1 — только начисления, -1 — только списания
+	Since      time.Time // не раньше
+	Until      time.Time // раньше
+}
+
+// Match сообщает, подходит ли запись под фильтр.
+func (f LogFilter) Match(entry models.LogEntry) bool {
+	switch {
+	case f.TelegramID != 0 && entry.TelegramID != f.TelegramID:
+		return false
+	case f.Resource != "" && !strings.EqualFold(entry.Resource, f.Resource):
+		return false
+	case f.Sign > 0 && entry.ChangeAmount <= 0, f.Sign < 0 && entry.ChangeAmount >= 0:
+		return false
+	case !f.Since.IsZero() && entry.Date.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.Date.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Ledger проводит операции с валютой и хранит их реестр.
+type Ledger interface {
+	// Transfer атомарно списывает amount ресурса resource у from и зачисляет его to,
+	// записывая операцию в реестр. Списание происходит только при достаточном балансе,
+	// иначе возвращается ErrInsufficientFunds. Возвращает обновлённые анкеты обеих сторон.
+	Transfer(ctx context.Context, from, to int64, resource string, amount int) (*models.UserProfile, *models.UserProfile, error)
+}
+
+// Events хранит ивенты.
+type Events interface {
+	// CreateEvent сохраняет новый ивент и заполняет его идентификатор.
+	CreateEvent(ctx context.Context, event *models.Event) error
+	// GetEvent возвращает ивент по идентификатору.
+	GetEvent(ctx context.Context, id primitive.ObjectID) (*models.Event, error)
+	// ListEvents возвращает ивенты с указанным статусом, от новых к старым.
+	ListEvents(ctx context.Context, status string) ([]models.Event, error)
+	// SetEventAnnouncement запоминает сообщение, которым был объявлен ивент.
+	SetEventAnnouncement(ctx context.Context, id primitive.ObjectID, ref models.MessageRef) error
+	// OpenEvent переводит запланированный ивент в статус active и возвращает обновлённый ивент.
+	// Если ивент уже начат или завершён, возвращается ErrEventStarted.
+	OpenEvent(ctx context.Context, id primitive.ObjectID) (*models.Event, error)
+	// MarkEventReminded отмечает, что напоминание по ивенту отправлено. Возвращает true,
+	// только если отметку поставил этот вызов, — так напоминание уходит один раз.
+	MarkEventReminded(ctx context.Context, id primitive.ObjectID) (bool, error)
+	// CloseEvent переводит активный или запланированный ивент в статус closed и возвращает обновлённый ивент.
+	// closedBy — Telegram ID завершившего (0 — по истечении времени). Если ивент уже завершён,
+	// возвращается ErrEventClosed, поэтому итоги ивента подводятся ровно один раз.
+	CloseEvent(ctx context.Context, id primitive.ObjectID, closedBy int64) (*models.Event, error)
+	// RespondToEvent записывает ответ игрока на ивент. Менять можно только пропуск на участие;
+	// при участии награда ивента атомарно зачисляется на баланс, записывается в реестр и журнал изменений ресурсов
+	// и возвращается обновлённая анкета (при пропуске — nil). Если ответ уже записан, возвращаются существующая запись и ErrAlreadyResponded;
+	// если ивент завершён — ErrEventClosed.
+	RespondToEvent(ctx context.Context, event *models.Event, profile *models.UserProfile, choice string) (*models.EventParticipant, *models.UserProfile, error)
+	// ListParticipants возвращает ответы игроков на ивент в порядке их поступления.
+	ListParticipants(ctx context.Context, eventID primitive.ObjectID) ([]models.EventParticipant, error)
+}
+
+// CustomFields хранит дополнительные поля анкеты, заданные администрацией.
+type CustomFields interface {
+	// CreateField добавляет поле и заполняет его идентификатор.
+	// Если поле с таким ключом уже есть, возвращается ErrFieldExists.
+	CreateField(ctx context.Context, field *models.ProfileField) error
+	// GetFieldByKey возвращает поле по ключу (см. models.ItemKey).
+	GetFieldByKey(ctx context.Context, key string) (*models.ProfileField, error)
+	// ListFields возвращает все поля в порядке добавления.
+	ListFields(ctx context.Context) ([]models.ProfileField, error)
+	// SetFieldFlags меняет обязательность поля и возможность игрокам заполнять его самим.
+	SetFieldFlags(ctx context.Context, id primitive.ObjectID, required, editable bool) error
+	// DeleteField удаляет поле и его значения из всех анкет.
+	DeleteField(ctx context.Context, id primitive.ObjectID) error
+}
+
+// Items хранит каталог предметов и изменяет инвентари персонажей.
+type Items interface {
+	// CreateItem добавляет предмет в каталог и заполняет его идентификатор.
+	// Если предмет с таким ключом уже есть, возвращается ErrItemExists.
+	CreateItem(ctx context.Context, item *models.Item) error
+	// GetItem возвращает предмет по идентификатору.
+	GetItem(ctx context.Context, id primitive.ObjectID) (*models.Item, error)
+	// GetItemByKey возвращает предмет по ключу (см. models.ItemKey).
+	GetItemByKey(ctx context.Context, key string) (*models.Item, error)
+	// ListItems возвращает весь каталог, упорядоченный по названию.
+	ListItems(ctx context.Context) ([]models.Item, error)
+	// SetItemDescription меняет описание предмета.
+	SetItemDescription(ctx context.Context, id primitive.ObjectID, description string) error
+	// DeleteItem удаляет предмет из каталога. Если предмет есть хотя бы у одного игрока,
+	// возвращается ErrItemInUse.
+	DeleteItem(ctx context.Context, id primitive.ObjectID) error
+	// AdjustItem атомарно изменяет количество предмета в инвентаре на delta и возвращает обновлённую анкету.
+	// Списание происходит только при достаточном количестве, иначе возвращается ErrInsufficientItems.
+	AdjustItem(ctx context.Context, telegramID int64, itemID primitive.ObjectID, delta int) (*models.UserProfile, error)
+}
+
+// Shop хранит товары магазина и проводит покупки.
+type Shop interface {
+	// AddListing выставляет предмет в магазин и заполняет идентификатор товара.
+	// Если предмет уже продаётся, возвращается ErrAlreadyListed.
+	AddListing(ctx context.Context, listing *models.ShopListing) error
+	// GetListing возвращает товар по идентификатору.
+	GetListing(ctx context.Context, id primitive.ObjectID) (*models.ShopListing, error)
+	// GetListingByItem возвращает товар по идентификатору предмета.
+	GetListingByItem(ctx context.Context, itemID primitive.ObjectID) (*models.ShopListing, error)
+	// ListListings возвращает все товары в порядке выставления.
+	ListListings(ctx context.Context) ([]models.ShopListing, error)
+	// SetListingPrice меняет цену и валюту товара.
+	SetListingPrice(ctx context.Context, id primitive.ObjectID, resource string, price int) error
+	// SetListingStock меняет запас товара (models.StockUnlimited — без ограничения).
+	SetListingStock(ctx context.Context, id primitive.ObjectID, stock int) error
+	// DeleteListing снимает товар с продажи.
+	DeleteListing(ctx context.Context, id primitive.ObjectID) error
+	// Buy атомарно проводит покупку quantity штук товара: уменьшает запас, списывает цену с баланса,
+	// добавляет предметы в инвентарь и записывает операцию в реестр. Если запаса не хватает,
+	// возвращается ErrOutOfStock, если не хватает средств — ErrInsufficientFunds; в этих случаях
+	// ничего не меняется. Возвращает обновлённые анкету покупателя и товар.
+	Buy(ctx context.Context, listingID primitive.ObjectID, telegramID int64, quantity int) (*models.UserProfile, *models.ShopListing, error)
+}
+
+// Trades хранит предложения обмена между игроками и проводит обмены.
+type Trades interface {
+	// CreateTrade сохраняет новое предложение и заполняет его идентификатор.
+	CreateTrade(ctx context.Context, trade *models.TradeOffer) error
+	// GetTrade возвращает предложение по идентификатору.
+	GetTrade(ctx context.Context, id primitive.ObjectID) (*models.TradeOffer, error)
+	// SetTradeMessages запоминает сообщения получателю (offer) и автору (notice) предложения.
+	SetTradeMessages(ctx context.Context, id primitive.ObjectID, offer, notice models.MessageRef) error
+	// DecideTrade переводит ожидающее предложение в status (отклонено или отменено) и возвращает его.
+	// Если предложение уже не ожидает ответа, возвращается ErrAlreadyDecided.
+	DecideTrade(ctx context.Context, id primitive.ObjectID, status string) (*models.TradeOffer, error)
+	// ExpireTrades переводит ожидающие предложения со сроком не позже now в статус expired и возвращает их.
+	ExpireTrades(ctx context.Context, now time.Time) ([]models.TradeOffer, error)
+	// ExecuteTrade атомарно проводит обмен: обе стороны отдают свои ценности, валюта записывается в реестр,
+	// предложение получает статус accepted. Если у стороны чего-то не хватает, возвращается ошибка
+	// ErrInsufficientFunds или ErrInsufficientItems с указанием стороны, и ничего не меняется.
+	// Для просроченного предложения возвращается ErrTradeExpired, для уже решённого — ErrAlreadyDecided.
+	// Возвращает предложение и обновлённые анкеты автора и получателя.
+	ExecuteTrade(ctx context.Context, id primitive.ObjectID, now time.Time) (*models.TradeOffer, *models.UserProfile, *models.UserProfile, error)
+}
+
+// State хранит служебное состояние бота.
+type State interface {
+	// LastUpdateID возвращает ID последнего обработанного обновления (0, если он не сохранялся).
+	LastUpdateID(ctx context.Context) (int, error)
+	// SaveLastUpdateID запоминает ID последнего обработанного обновления.
+	SaveLastUpdateID(ctx context.Context, id int) error
+}
+
+// Conversations хранит незавершённые многошаговые диалоги с пользователями.
+type Conversations interface {
+	// GetConversation возвращает диалог пользователя. Если диалога нет или его срок истёк,
+	// возвращается ErrNotFound.
+	GetConversation(ctx context.Context, telegramID int64) (*models.Conversation, error)
+	// SaveConversation создаёт диалог или перезаписывает существующий диалог пользователя.
+	SaveConversation(ctx context.Context, conv models.Conversation) error
+	// DeleteConversation удаляет диалог пользователя (если его нет, ничего не происходит).
+	DeleteConversation(ctx context.Context, telegramID int64) error
+	// ClearConversations удаляет все диалоги и возвращает их количество.
+	ClearConversations(ctx context.Context) (int, error)
+}
+
+// Grants хранит заявки игроков на пополнение ресурсов.
+type Grants interface {
+	// CreateGrantRequest сохраняет новую заявку и заполняет её идентификатор.
+	CreateGrantRequest(ctx context.Context, request *models.GrantRequest) error
+	// GetGrantRequest возвращает заявку по идентификатору.
+	GetGrantRequest(ctx context.Context, id primitive.ObjectID) (*models.GrantRequest, error)
+	// ListGrantRequests возвращает заявки игрока с указанным статусом, от новых к старым.
+	ListGrantRequests(ctx context.Context, telegramID int64, status string) ([]models.GrantRequest, error)
+	// SetGrantNotifications запоминает сообщения, разосланные администрации по заявке.
+	SetGrantNotifications(ctx context.Context, id primitive.ObjectID, refs []models.MessageRef) error
+	// DecideGrantRequest переводит заявку из статуса pending в status и возвращает обновлённую заявку.
+	// Если заявка уже рассмотрена, возвращается ErrAlreadyDecided.
+	DecideGrantRequest(ctx context.Context, id primitive.ObjectID, status string, adminID int64) (*models.GrantRequest, error)
+	// ApproveGrantRequest атомарно одобряет заявку в ожидании: меняет её статус, зачисляет ресурс игроку
+	// и записывает операцию в реестр. Если заявка уже рассмотрена, возвращается ErrAlreadyDecided;
+	// если нет заявки или анкеты игрока — ErrNotFound. В этих случаях ничего не меняется.
+	// Возвращает одобренную заявку и обновлённую анкету.
+	ApproveGrantRequest(ctx context.Context, id primitive.ObjectID, adminID int64) (*models.GrantRequest, *models.UserProfile, error)
+}
+
+// AuditFilter задаёт условия выборки из журнала действий. Пустые поля не ограничивают выборку.
+type AuditFilter struct {
+	ActorUsername string    // в нижнем регистре, без @
+	Target        string    // точное значение цели
+	Since         time.Time // не раньше
+	Until         time.Time // раньше
+	Limit         int       // не больше стольких записей; 0 — без ограничения
+}
+
+// Audit хранит журнал действий администрации. Записи только добавляются.
+type Audit interface {
+	// AddAudit добавляет запись в журнал действий.
+	AddAudit(ctx context.Context, entry models.AuditEntry) error
+	// FindAudit возвращает записи журнала по фильтру, от новых к старым.
+	FindAudit(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error)
+}
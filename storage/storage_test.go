@@ -0,0 +1,790 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"telegram-bot-go/models"
+)
+
+// Тесты ниже проверяют обе реализации Storage одинаково. Хранилище в памяти проверяется всегда,
+// MongoDB — если задан MONGO_TEST_URI (для Transfer нужен replica set), например:
+//
+//	MONGO_TEST_URI=mongodb://localhost:27017/?replicaSet=rs0 go test ./storage
+
+// implementation создаёт пустое хранилище одной из реализаций.
+type implementation struct {
+	name string
+	new  func(t *testing.T) Storage
+}
+
+func implementations() []implementation {
+	return []implementation{
+		{"memory", func(t *testing.T) Storage { return NewMemory() }},
+		{"mongo", newTestMongo},
+	}
+}
+
+// newTestMongo создаёт хранилище в отдельной базе MONGO_TEST_URI, которая удаляется после теста.
+func newTestMongo(t *testing.T) Storage {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI не задан")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := client.Database(fmt.Sprintf("bot_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		database.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	s, err := NewMongo(ctx, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// forEachStorage запускает тест для каждой реализации с заранее сохранёнными анкетами.
+func forEachStorage(t *testing.T, profiles []models.UserProfile, test func(t *testing.T, s Storage)) {
+	for _, impl := range implementations() {
+		t.Run(impl.name, func(t *testing.T) {
+			s := impl.new(t)
+			for _, p := range profiles {
+				if err := s.SaveProfile(context.Background(), p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			test(t, s)
+		})
+	}
+}
+
+// ledgerEntries возвращает все записи реестра хранилища.
+func ledgerEntries(t *testing.T, s Storage) []models.LedgerEntry {
+	t.Helper()
+	switch s := s.(type) {
+	case *MemoryStorage:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return append([]models.LedgerEntry(nil), s.ledger...)
+	case *MongoStorage:
+		ctx := context.Background()
+		cursor, err := s.ledger.Find(ctx, bson.M{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []models.LedgerEntry
+		if err := cursor.All(ctx, &entries); err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+	t.Fatalf("неизвестная реализация %T", s)
+	return nil
+}
+
+func TestGetProfileNotFound(t *testing.T) {
+	forEachStorage(t, []models.UserProfile{{TelegramID: 1, Username: "jack"}}, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		if _, err := s.GetProfile(ctx, 2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetProfile: ошибка %v, ожидалась ErrNotFound", err)
+		}
+		if _, err := s.GetProfileByUsername(ctx, "anne"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetProfileByUsername: ошибка %v, ожидалась ErrNotFound", err)
+		}
+		if _, err := s.IncrementBalance(ctx, 2, map[string]int{models.ResourceOblomki: 1}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("IncrementBalance: ошибка %v, ожидалась ErrNotFound", err)
+		}
+		if err := s.DeleteProfile(ctx, 2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteProfile: ошибка %v, ожидалась ErrNotFound", err)
+		}
+		if _, err := s.GetProfile(ctx, 1); err != nil {
+			t.Errorf("GetProfile существующей анкеты: %v", err)
+		}
+	})
+}
+
+func TestIncrementBalance(t *testing.T) {
+	profiles := []models.UserProfile{{TelegramID: 1, Oblomki: 10, Piastry: 5}}
+	forEachStorage(t, profiles, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		updated, err := s.IncrementBalance(ctx, 1, map[string]int{models.ResourceOblomki: 3, models.ResourcePiastry: -2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Oblomki != 13 || updated.Piastry != 3 {
+			t.Errorf("возвращён баланс %d/%d, ожидался 13/3", updated.Oblomki, updated.Piastry)
+		}
+		stored, err := s.GetProfile(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Oblomki != 13 || stored.Piastry != 3 {
+			t.Errorf("сохранён баланс %d/%d, ожидался 13/3", stored.Oblomki, stored.Piastry)
+		}
+	})
+}
+
+func TestDebitBalance(t *testing.T) {
+	forEachStorage(t, []models.UserProfile{{TelegramID: 1, Piastry: 5}}, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		if _, err := s.DebitBalance(ctx, 1, models.ResourcePiastry, 6); !errors.Is(err, ErrInsufficientFunds) {
+			t.Fatalf("ошибка %v, ожидалась ErrInsufficientFunds", err)
+		}
+		updated, err := s.DebitBalance(ctx, 1, models.ResourcePiastry, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Piastry != 0 {
+			t.Errorf("баланс %d, ожидался 0", updated.Piastry)
+		}
+		if _, err := s.DebitBalance(ctx, 2, models.ResourcePiastry, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ошибка %v, ожидалась ErrNotFound", err)
+		}
+		// В реестр попадает только удачное списание.
+		entries := ledgerEntries(t, s)
+		if len(entries) != 1 {
+			t.Fatalf("записей в реестре: %d, ожидалась 1", len(entries))
+		}
+		if e := entries[0]; e.Kind != models.LedgerDebit || e.Amount != 5 || e.From.TelegramID != 1 || e.From.BalanceAfter != 0 {
+			t.Errorf("запись реестра %+v не соответствует списанию", e)
+		}
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	profiles := []models.UserProfile{
+		{TelegramID: 1, Username: "jack", Oblomki: 10},
+		{TelegramID: 2, Username: "anne", Oblomki: 1},
+	}
+	forEachStorage(t, profiles, func(t *testing.T, s Storage) {
+		donor, recipient, err := s.Transfer(context.Background(), 1, 2, models.ResourceOblomki, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if donor.Oblomki != 6 || recipient.Oblomki != 5 {
+			t.Errorf("балансы после передачи %d и %d, ожидались 6 и 5", donor.Oblomki, recipient.Oblomki)
+		}
+		entries := ledgerEntries(t, s)
+		if len(entries) != 1 {
+			t.Fatalf("записей в реестре: %d, ожидалась 1", len(entries))
+		}
+		if e := entries[0]; e.Kind != models.LedgerTransfer || e.Amount != 4 || e.From.BalanceAfter != 6 || e.To.BalanceAfter != 5 {
+			t.Errorf("запись реестра %+v не соответствует передаче", e)
+		}
+	})
+}
+
+func TestTransferInsufficientFunds(t *testing.T) {
+	profiles := []models.UserProfile{
+		{TelegramID: 1, Username: "jack", Piastry: 3},
+		{TelegramID: 2, Username: "anne"},
+	}
+	forEachStorage(t, profiles, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		if _, _, err := s.Transfer(ctx, 1, 2, models.ResourcePiastry, 4); !errors.Is(err, ErrInsufficientFunds) {
+			t.Fatalf("ошибка %v, ожидалась ErrInsufficientFunds", err)
+		}
+		// Неудачная передача не меняет балансы и не попадает в реестр.
+		donor, err := s.GetProfile(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipient, err := s.GetProfile(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if donor.Piastry != 3 || recipient.Piastry != 0 {
+			t.Errorf("балансы изменились: %d и %d", donor.Piastry, recipient.Piastry)
+		}
+		if n := len(ledgerEntries(t, s)); n != 0 {
+			t.Errorf("записей в реестре: %d, ожидалось 0", n)
+		}
+		if _, _, err := s.Transfer(ctx, 1, 3, models.ResourcePiastry, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("передача несуществующему получателю: ошибка %v, ожидалась ErrNotFound", err)
+		}
+	})
+}
+
+func TestDeleteFieldKeepsReturnedProfiles(t *testing.T) {
+	forEachStorage(t, nil, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		field := &models.ProfileField{Key: "корабль", Name: "Корабль", Type: models.FieldText}
+		if err := s.CreateField(ctx, field); err != nil {
+			t.Fatal(err)
+		}
+		err := s.SaveProfile(ctx, models.UserProfile{TelegramID: 1, Fields: map[string]string{field.ID.Hex(): "Жемчужина"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		before, err := s.GetProfile(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed, err := s.ListProfiles(ctx, SortNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteField(ctx, field.ID); err != nil {
+			t.Fatal(err)
+		}
+		// Анкеты, полученные до удаления, принадлежат вызывающему и не меняются.
+		if before.Fields[field.ID.Hex()] != "Жемчужина" || listed[0].Fields[field.ID.Hex()] != "Жемчужина" {
+			t.Error("удаление поля изменило ранее полученные анкеты")
+		}
+		after, err := s.GetProfile(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := after.Fields[field.ID.Hex()]; ok {
+			t.Error("значение удалённого поля осталось в анкете")
+		}
+		// Изменения полученной анкеты не попадают в хранилище.
+		before.Fields["чужое"] = "значение"
+		if again, _ := s.GetProfile(ctx, 1); again.Fields["чужое"] != "" {
+			t.Error("изменение полученной анкеты попало в хранилище")
+		}
+	})
+}
+
+func TestRespondToEventRecordsReward(t *testing.T) {
+	profiles := []models.UserProfile{{TelegramID: 1, Username: "jack", Name: "Джек", Oblomki: 2}}
+	forEachStorage(t, profiles, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		event := &models.Event{Name: "Шторм", Status: models.EventActive, Oblomki: 5, Piastry: 3}
+		if err := s.CreateEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+		profile, err := s.GetProfile(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, updated, err := s.RespondToEvent(ctx, event, profile, models.ChoiceParticipate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Oblomki != 7 || updated.Piastry != 3 {
+			t.Errorf("баланс %d/%d, ожидался 7/3", updated.Oblomki, updated.Piastry)
+		}
+
+		entries := ledgerEntries(t, s)
+		if len(entries) != 2 {
+			t.Fatalf("записей в реестре: %d, ожидалось 2", len(entries))
+		}
+		for _, e := range entries {
+			if e.Kind != models.LedgerEvent || e.To.TelegramID != 1 || e.To.BalanceAfter != updated.Balance(e.Resource) {
+				t.Errorf("запись реестра %+v не соответствует награде", e)
+			}
+		}
+		logs, err := s.FindLogs(ctx, LogFilter{TelegramID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]int{}
+		for _, l := range logs {
+			got[l.Resource] += l.ChangeAmount
+		}
+		if len(logs) != 2 || got["обломки"] != 5 || got["пиастры"] != 3 {
+			t.Errorf("журнал изменений %+v, ожидались +5 обломков и +3 пиастры", logs)
+		}
+
+		// Пропуск ивента ничего не начисляет и не записывает.
+		if err := s.SaveProfile(ctx, models.UserProfile{TelegramID: 2, Username: "anne"}); err != nil {
+			t.Fatal(err)
+		}
+		anne, _ := s.GetProfile(ctx, 2)
+		if _, _, err := s.RespondToEvent(ctx, event, anne, models.ChoiceSkip); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(ledgerEntries(t, s)); n != 2 {
+			t.Errorf("записей в реестре после пропуска: %d, ожидалось 2", n)
+		}
+	})
+}
+
+func TestApproveGrantRequest(t *testing.T) {
+	profiles := []models.UserProfile{{TelegramID: 1, Username: "jack", Oblomki: 2}}
+	forEachStorage(t, profiles, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		request := &models.GrantRequest{TelegramID: 1, Resource: models.ResourceOblomki, ResourceLabel: "обломки", Amount: 5, Status: models.GrantPending}
+		if err := s.CreateGrantRequest(ctx, request); err != nil {
+			t.Fatal(err)
+		}
+		approved, profile, err := s.ApproveGrantRequest(ctx, request.ID, 9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if approved.Status != models.GrantApproved || approved.DecidedBy != 9 {
+			t.Errorf("заявка %+v не одобрена администратором 9", approved)
+		}
+		if profile.Oblomki != 7 {
+			t.Errorf("баланс %d, ожидался 7", profile.Oblomki)
+		}
+		entries := ledgerEntries(t, s)
+		if len(entries) != 1 {
+			t.Fatalf("записей в реестре: %d, ожидалась 1", len(entries))
+		}
+		if e := entries[0]; e.Kind != models.LedgerGrant || e.Amount != 5 || e.From.TelegramID != 9 || e.To.TelegramID != 1 || e.To.BalanceAfter != 7 {
+			t.Errorf("запись реестра %+v не соответствует заявке", e)
+		}
+
+		// Повторное одобрение ничего не зачисляет.
+		if _, _, err := s.ApproveGrantRequest(ctx, request.ID, 9); !errors.Is(err, ErrAlreadyDecided) {
+			t.Errorf("повторное одобрение: ошибка %v, ожидалась ErrAlreadyDecided", err)
+		}
+		if stored, _ := s.GetProfile(ctx, 1); stored.Oblomki != 7 {
+			t.Errorf("баланс после повторного одобрения %d, ожидался 7", stored.Oblomki)
+		}
+		if n := len(ledgerEntries(t, s)); n != 1 {
+			t.Errorf("записей в реестре после повторного одобрения: %d, ожидалась 1", n)
+		}
+	})
+}
+
+func TestApproveGrantRequestWithoutProfile(t *testing.T) {
+	forEachStorage(t, nil, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		request := &models.GrantRequest{TelegramID: 1, Resource: models.ResourcePiastry, Amount: 3, Status: models.GrantPending}
+		if err := s.CreateGrantRequest(ctx, request); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.ApproveGrantRequest(ctx, request.ID, 9); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ошибка %v, ожидалась ErrNotFound", err)
+		}
+		// Без анкеты заявка остаётся в ожидании, и её можно рассмотреть снова.
+		stored, err := s.GetGrantRequest(ctx, request.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status != models.GrantPending {
+			t.Errorf("статус заявки %q, ожидался %q", stored.Status, models.GrantPending)
+		}
+		if n := len(ledgerEntries(t, s)); n != 0 {
+			t.Errorf("записей в реестре: %d, ожидалось 0", n)
+		}
+		if _, _, err := s.ApproveGrantRequest(ctx, primitive.NewObjectID(), 9); !errors.Is(err, ErrNotFound) {
+			t.Errorf("одобрение несуществующей заявки: ошибка %v, ожидалась ErrNotFound", err)
+		}
+	})
+}
+
+// legacyString возвращает строковое значение поля в прежнем формате анкет.
+func legacyString(t *testing.T, s string) bson.RawValue {
+	t.Helper()
+	typ, data, err := bson.MarshalValue(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bson.RawValue{Type: typ, Value: data}
+}
+
+func TestMeasurementUpdate(t *testing.T) {
+	tests := []struct {
+		name         string
+		age, hw      *string // nil — поля нет в документе
+		wantSet      bson.M
+		wantUnset    bson.M
+		wantProblems int
+	}{
+		{
+			name:      "возраст и рост с весом",
+			age:       ptr("30 лет"),
+			hw:        ptr(`173.6 см\70 кг`),
+			wantSet:   bson.M{"age": 30, "height_cm": 173.6, "weight_kg": 70.0},
+			wantUnset: bson.M{"height_weight": ""},
+		},
+		{
+			name:      "только рост и вес",
+			hw:        ptr("180см/75кг"),
+			wantSet:   bson.M{"height_cm": 180.0, "weight_kg": 75.0},
+			wantUnset: bson.M{"height_weight": ""},
+		},
+		{
+			name:         "неразборчивые значения",
+			age:          ptr(" около 300 "),
+			hw:           ptr("высокий и худой"),
+			wantSet:      bson.M{"age_note": "около 300", "height_weight_note": "высокий и худой"},
+			wantUnset:    bson.M{"age": "", "height_weight": ""},
+			wantProblems: 2,
+		},
+		{
+			name:      "пустые строки",
+			age:       ptr(""),
+			hw:        ptr("  "),
+			wantUnset: bson.M{"age": "", "height_weight": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var age, hw bson.RawValue
+			if tt.age != nil {
+				age = legacyString(t, *tt.age)
+			}
+			if tt.hw != nil {
+				hw = legacyString(t, *tt.hw)
+			}
+			update, problems := measurementUpdate(age, hw)
+			if got, _ := update["$set"].(bson.M); !equalDocs(got, tt.wantSet) {
+				t.Errorf("$set = %v, ожидалось %v", got, tt.wantSet)
+			}
+			if got, _ := update["$unset"].(bson.M); !equalDocs(got, tt.wantUnset) {
+				t.Errorf("$unset = %v, ожидалось %v", got, tt.wantUnset)
+			}
+			if len(problems) != tt.wantProblems {
+				t.Errorf("неразобранные значения %q, ожидалось %d", problems, tt.wantProblems)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+// equalDocs сравнивает документы обновления; отсутствующий документ равен пустому.
+func equalDocs(a, b bson.M) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMigrateMeasurements(t *testing.T) {
+	s := newTestMongo(t).(*MongoStorage)
+	ctx := context.Background()
+	_, err := s.users.InsertMany(ctx, []interface{}{
+		bson.M{"telegram_id": 1, "username": "jack", "age": "30 лет", "height_weight": `173.6 см\70 кг`},
+		bson.M{"telegram_id": 2, "username": "anne", "age": "бессмертный", "height_weight": "Рост 180, вес 80"},
+		bson.M{"telegram_id": 3, "username": "will", "age": 25, "height_cm": 185.0, "weight_kg": 80.0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Повторный запуск ничего не меняет.
+	for i := 0; i < 2; i++ {
+		if err := s.migrateMeasurements(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jack, err := s.GetProfile(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jack.Age != 30 || jack.HeightCm != 173.6 || jack.WeightKg != 70 || jack.AgeNote != "" || jack.HeightWeightNote != "" {
+		t.Errorf("разобранная анкета %+v", jack)
+	}
+	anne, err := s.GetProfile(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anne.Age != 0 || anne.AgeNote != "бессмертный" || anne.HeightCm != 0 || anne.HeightWeightNote != "Рост 180, вес 80" {
+		t.Errorf("неразобранные значения не перенесены в заметки: %+v", anne)
+	}
+	will, err := s.GetProfile(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if will.Age != 25 || will.HeightCm != 185 || will.WeightKg != 80 {
+		t.Errorf("уже перенесённая анкета изменилась: %+v", will)
+	}
+	if n, err := s.users.CountDocuments(ctx, bson.M{"height_weight": bson.M{"$exists": true}}); err != nil || n != 0 {
+		t.Errorf("осталось строковых полей height_weight: %d (%v)", n, err)
+	}
+}
+
+// Предметы и анкеты для тестов обмена: Джек отдаёт обломки и меч, Анна — пиастры и карту.
+var (
+	swordID = primitive.NewObjectID()
+	mapID   = primitive.NewObjectID()
+)
+
+func tradeProfiles() []models.UserProfile {
+	return []models.UserProfile{
+		{TelegramID: 1, Username: "jack", Oblomki: 10, Items: []models.InventoryItem{{ItemID: swordID, Quantity: 2}}},
+		{TelegramID: 2, Username: "anne", Piastry: 20, Items: []models.InventoryItem{{ItemID: mapID, Quantity: 1}}},
+	}
+}
+
+// createTrade сохраняет ожидающее предложение Джека Анне со сроком expires.
+func createTrade(t *testing.T, s Storage, give, take models.TradeGoods, expires time.Time) *models.TradeOffer {
+	t.Helper()
+	trade := &models.TradeOffer{
+		CreatedAt: time.Now(),
+		ExpiresAt: expires,
+		From:      models.TradeParty{TelegramID: 1, Username: "jack"},
+		To:        models.TradeParty{TelegramID: 2, Username: "anne"},
+		Give:      give,
+		Take:      take,
+		Status:    models.TradePending,
+	}
+	if err := s.CreateTrade(context.Background(), trade); err != nil {
+		t.Fatal(err)
+	}
+	return trade
+}
+
+// holdings описывает валюту и предметы анкеты для сравнения до и после обмена.
+func holdings(t *testing.T, s Storage, telegramID int64) string {
+	t.Helper()
+	p, err := s.GetProfile(context.Background(), telegramID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("обломки=%d пиастры=%d меч=%d карта=%d",
+		p.Oblomki, p.Piastry, p.ItemQuantity(swordID), p.ItemQuantity(mapID))
+}
+
+func TestExecuteTrade(t *testing.T) {
+	forEachStorage(t, tradeProfiles(), func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		now := time.Now()
+		trade := createTrade(t, s,
+			models.TradeGoods{Oblomki: 4, Items: []models.InventoryItem{{ItemID: swordID, Quantity: 1}}},
+			models.TradeGoods{Piastry: 15, Items: []models.InventoryItem{{ItemID: mapID, Quantity: 1}}},
+			now.Add(time.Hour))
+
+		accepted, from, to, err := s.ExecuteTrade(ctx, trade.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accepted.Status != models.TradeAccepted {
+			t.Errorf("статус %q, ожидался %q", accepted.Status, models.TradeAccepted)
+		}
+		if from.Oblomki != 6 || to.Piastry != 5 {
+			t.Errorf("возвращены балансы %d и %d, ожидались 6 и 5", from.Oblomki, to.Piastry)
+		}
+		if got, want := holdings(t, s, 1), "обломки=6 пиастры=15 меч=1 карта=1"; got != want {
+			t.Errorf("у Джека %s, ожидалось %s", got, want)
+		}
+		if got, want := holdings(t, s, 2), "обломки=4 пиастры=5 меч=1 карта=0"; got != want {
+			t.Errorf("у Анны %s, ожидалось %s", got, want)
+		}
+
+		// В реестр попадает только валюта: по записи на каждое направление.
+		entries := ledgerEntries(t, s)
+		if len(entries) != 2 {
+			t.Fatalf("записей в реестре: %d, ожидалось 2", len(entries))
+		}
+		for _, e := range entries {
+			switch {
+			case e.Kind != models.LedgerTrade:
+				t.Errorf("вид записи %q, ожидался %q", e.Kind, models.LedgerTrade)
+			case e.Resource == models.ResourceOblomki:
+				if e.Amount != 4 || e.From.TelegramID != 1 || e.To.TelegramID != 2 || e.From.BalanceAfter != 6 || e.To.BalanceAfter != 4 {
+					t.Errorf("запись об обломках %+v", e)
+				}
+			case e.Resource == models.ResourcePiastry:
+				if e.Amount != 15 || e.From.TelegramID != 2 || e.To.TelegramID != 1 || e.From.BalanceAfter != 5 || e.To.BalanceAfter != 15 {
+					t.Errorf("запись о пиастрах %+v", e)
+				}
+			}
+		}
+
+		if _, _, _, err := s.ExecuteTrade(ctx, trade.ID, now); !errors.Is(err, ErrAlreadyDecided) {
+			t.Errorf("повторное принятие: ошибка %v, ожидалась ErrAlreadyDecided", err)
+		}
+	})
+}
+
+func TestExecuteTradeFailsWithoutChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		give    models.TradeGoods
+		take    models.TradeGoods
+		wantErr error
+		party   string // сторона, у которой не хватает
+	}{
+		{"автору не хватает валюты", models.TradeGoods{Oblomki: 11}, models.TradeGoods{Piastry: 1}, ErrInsufficientFunds, "@jack"},
+		{"автору не хватает предметов", models.TradeGoods{Oblomki: 1, Items: []models.InventoryItem{{ItemID: swordID, Quantity: 3}}}, models.TradeGoods{Piastry: 1}, ErrInsufficientItems, "@jack"},
+		{"получателю не хватает валюты", models.TradeGoods{Oblomki: 1}, models.TradeGoods{Piastry: 21}, ErrInsufficientFunds, "@anne"},
+		{"получателю не хватает предметов", models.TradeGoods{Oblomki: 1, Items: []models.InventoryItem{{ItemID: swordID, Quantity: 1}}}, models.TradeGoods{Piastry: 1, Items: []models.InventoryItem{{ItemID: mapID, Quantity: 2}}}, ErrInsufficientItems, "@anne"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStorage(t, tradeProfiles(), func(t *testing.T, s Storage) {
+				ctx := context.Background()
+				now := time.Now()
+				trade := createTrade(t, s, tt.give, tt.take, now.Add(time.Hour))
+				jack, anne := holdings(t, s, 1), holdings(t, s, 2)
+
+				_, _, _, err := s.ExecuteTrade(ctx, trade.ID, now)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ошибка %v, ожидалась %v", err, tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), tt.party) {
+					t.Errorf("ошибка %q не указывает сторону %s", err, tt.party)
+				}
+				if got := holdings(t, s, 1); got != jack {
+					t.Errorf("у Джека %s, было %s", got, jack)
+				}
+				if got := holdings(t, s, 2); got != anne {
+					t.Errorf("у Анны %s, было %s", got, anne)
+				}
+				if n := len(ledgerEntries(t, s)); n != 0 {
+					t.Errorf("записей в реестре: %d, ожидалось 0", n)
+				}
+				if stored, _ := s.GetTrade(ctx, trade.ID); stored.Status != models.TradePending {
+					t.Errorf("статус %q, предложение должно остаться в ожидании", stored.Status)
+				}
+			})
+		})
+	}
+}
+
+func TestExecuteTradeExpiredOrDecided(t *testing.T) {
+	forEachStorage(t, tradeProfiles(), func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		now := time.Now()
+		goods := models.TradeGoods{Oblomki: 1}
+		expired := createTrade(t, s, goods, models.TradeGoods{Piastry: 1}, now.Add(-time.Minute))
+		declined := createTrade(t, s, goods, models.TradeGoods{Piastry: 1}, now.Add(time.Hour))
+		if _, err := s.DecideTrade(ctx, declined.ID, models.TradeDeclined); err != nil {
+			t.Fatal(err)
+		}
+		jack, anne := holdings(t, s, 1), holdings(t, s, 2)
+
+		if _, _, _, err := s.ExecuteTrade(ctx, expired.ID, now); !errors.Is(err, ErrTradeExpired) {
+			t.Errorf("просроченное предложение: ошибка %v, ожидалась ErrTradeExpired", err)
+		}
+		if _, _, _, err := s.ExecuteTrade(ctx, declined.ID, now); !errors.Is(err, ErrAlreadyDecided) {
+			t.Errorf("отклонённое предложение: ошибка %v, ожидалась ErrAlreadyDecided", err)
+		}
+		if _, _, _, err := s.ExecuteTrade(ctx, primitive.NewObjectID(), now); !errors.Is(err, ErrNotFound) {
+			t.Errorf("несуществующее предложение: ошибка %v, ожидалась ErrNotFound", err)
+		}
+		if holdings(t, s, 1) != jack || holdings(t, s, 2) != anne || len(ledgerEntries(t, s)) != 0 {
+			t.Error("непроведённый обмен изменил анкеты или реестр")
+		}
+		if stored, _ := s.GetTrade(ctx, declined.ID); stored.Status != models.TradeDeclined {
+			t.Errorf("статус отклонённого предложения %q", stored.Status)
+		}
+	})
+}
+
+// addListing выставляет в магазин меч по цене price пиастр с запасом stock.
+func addListing(t *testing.T, s Storage, price, stock int) *models.ShopListing {
+	t.Helper()
+	listing := &models.ShopListing{ItemID: swordID, Resource: models.ResourcePiastry, Price: price, Stock: stock, CreatedAt: time.Now()}
+	if err := s.AddListing(context.Background(), listing); err != nil {
+		t.Fatal(err)
+	}
+	return listing
+}
+
+func TestBuy(t *testing.T) {
+	profiles := []models.UserProfile{{TelegramID: 1, Username: "jack", Name: "Джек", Piastry: 20}}
+	forEachStorage(t, profiles, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		listing := addListing(t, s, 3, 5)
+
+		buyer, updated, err := s.Buy(ctx, listing.ID, 1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buyer.Piastry != 14 || buyer.ItemQuantity(swordID) != 2 || updated.Stock != 3 {
+			t.Errorf("после покупки баланс %d, мечей %d, запас %d; ожидалось 14, 2, 3",
+				buyer.Piastry, buyer.ItemQuantity(swordID), updated.Stock)
+		}
+		entries := ledgerEntries(t, s)
+		if len(entries) != 1 {
+			t.Fatalf("записей в реестре: %d, ожидалась 1", len(entries))
+		}
+		if e := entries[0]; e.Kind != models.LedgerPurchase || e.Resource != models.ResourcePiastry || e.Amount != 6 ||
+			e.From.TelegramID != 1 || e.From.BalanceAfter != 14 || e.To.Name != "Магазин" {
+			t.Errorf("запись реестра %+v не соответствует покупке", e)
+		}
+
+		// Последние штуки раскупаются, после чего товар заканчивается.
+		if _, updated, err = s.Buy(ctx, listing.ID, 1, 3); err != nil {
+			t.Fatal(err)
+		}
+		if updated.Stock != 0 {
+			t.Errorf("запас %d, ожидался 0", updated.Stock)
+		}
+		if _, _, err := s.Buy(ctx, listing.ID, 1, 1); !errors.Is(err, ErrOutOfStock) {
+			t.Errorf("покупка закончившегося товара: ошибка %v, ожидалась ErrOutOfStock", err)
+		}
+	})
+}
+
+func TestBuyFailsWithoutChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		stock    int
+		quantity int
+		wantErr  error
+	}{
+		{"запас закончился", 0, 1, ErrOutOfStock},
+		{"запаса не хватает", 2, 3, ErrOutOfStock},
+		{"не хватает средств", 10, 4, ErrInsufficientFunds},
+		{"не хватает средств при неограниченном запасе", models.StockUnlimited, 4, ErrInsufficientFunds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profiles := []models.UserProfile{{TelegramID: 1, Username: "jack", Piastry: 10}}
+			forEachStorage(t, profiles, func(t *testing.T, s Storage) {
+				ctx := context.Background()
+				listing := addListing(t, s, 3, tt.stock)
+				if _, _, err := s.Buy(ctx, listing.ID, 1, tt.quantity); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ошибка %v, ожидалась %v", err, tt.wantErr)
+				}
+				if got := holdings(t, s, 1); got != "обломки=0 пиастры=10 меч=0 карта=0" {
+					t.Errorf("анкета изменилась: %s", got)
+				}
+				if stored, _ := s.GetListing(ctx, listing.ID); stored.Stock != tt.stock {
+					t.Errorf("запас %d, ожидался прежний %d", stored.Stock, tt.stock)
+				}
+				if n := len(ledgerEntries(t, s)); n != 0 {
+					t.Errorf("записей в реестре: %d, ожидалось 0", n)
+				}
+			})
+		})
+	}
+}
+
+func TestBuyUnlimitedStock(t *testing.T) {
+	profiles := []models.UserProfile{{TelegramID: 1, Username: "jack", Piastry: 100}}
+	forEachStorage(t, profiles, func(t *testing.T, s Storage) {
+		ctx := context.Background()
+		listing := addListing(t, s, 1, models.StockUnlimited)
+		for i := 0; i < 3; i++ {
+			if _, _, err := s.Buy(ctx, listing.ID, 1, 10); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stored, err := s.GetListing(ctx, listing.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Stock != models.StockUnlimited {
+			t.Errorf("запас %d, должен остаться неограниченным", stored.Stock)
+		}
+		if got := holdings(t, s, 1); got != "обломки=0 пиастры=70 меч=30 карта=0" {
+			t.Errorf("после покупок %s", got)
+		}
+		if n := len(ledgerEntries(t, s)); n != 3 {
+			t.Errorf("записей в реестре: %d, ожидалось 3", n)
+		}
+	})
+}
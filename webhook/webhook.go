@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SecretHeader — заголовок, в котором Telegram передаёт secret_token, указанный при setWebhook.
+const SecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// Handler принимает обновления от Telegram по HTTP и передаёт их в submit —
+// тот же путь обработки, что и при long polling.
+type Handler struct {
+	secret string
+	submit func(tgbotapi.Update)
+}
+
+// NewHandler создаёт обработчик вебхука. Запросы без правильного секретного токена отклоняются.
+func NewHandler(secret string, submit func(tgbotapi.Update)) *Handler {
+	return &Handler{secret: secret, submit: submit}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.Header.Get(SecretHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var update tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+		log.Printf("Ошибка разбора обновления из вебхука: %v", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	h.submit(update)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Register сообщает Telegram адрес вебхука и секретный токен, который нужно присылать в заголовке.
+func Register(bot *tgbotapi.BotAPI, url, secret string) error {
+	resp, err := bot.MakeRequest("setWebhook", tgbotapi.Params{
+		"url":          url,
+		"secret_token": secret,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("setWebhook: %s", resp.Description)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testSecret = "test-secret"
+
+// recordedUpdates — обновления, записанные для webhook-replay.
+const recordedUpdates = "../cmd/webhook-replay/updates/*.json"
+
+func TestHandlerRejectsRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		want   int
+	}{
+		{"без токена", http.MethodPost, "", http.StatusForbidden},
+		{"неверный токен", http.MethodPost, "wrong-secret", http.StatusForbidden},
+		{"GET", http.MethodGet, testSecret, http.StatusMethodNotAllowed},
+		{"PUT", http.MethodPut, testSecret, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submitted := false
+			h := NewHandler(testSecret, func(tgbotapi.Update) { submitted = true })
+			req := httptest.NewRequest(tt.method, "/telegram", bytes.NewReader([]byte(`{"update_id": 1}`)))
+			if tt.secret != "" {
+				req.Header.Set(SecretHeader, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("код ответа %d, ожидался %d", rec.Code, tt.want)
+			}
+			if submitted {
+				t.Error("отклонённое обновление передано на обработку")
+			}
+		})
+	}
+}
+
+func TestHandlerAcceptsRecordedUpdates(t *testing.T) {
+	paths, err := filepath.Glob(recordedUpdates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("нет записанных обновлений %s", recordedUpdates)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			body, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want tgbotapi.Update
+			if err := json.Unmarshal(body, &want); err != nil {
+				t.Fatalf("разбор %s: %v", path, err)
+			}
+
+			var got []tgbotapi.Update
+			h := NewHandler(testSecret, func(update tgbotapi.Update) { got = append(got, update) })
+			server := httptest.NewServer(h)
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(SecretHeader, testSecret)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("код ответа %d, ожидался %d", resp.StatusCode, http.StatusOK)
+			}
+			if len(got) != 1 {
+				t.Fatalf("передано обновлений: %d, ожидалось 1", len(got))
+			}
+			if got[0].UpdateID != want.UpdateID {
+				t.Errorf("update_id %d, ожидался %d", got[0].UpdateID, want.UpdateID)
+			}
+			if (got[0].Message == nil) != (want.Message == nil) || (got[0].CallbackQuery == nil) != (want.CallbackQuery == nil) {
+				t.Fatalf("вид обновления не совпадает с записанным")
+			}
+			if want.Message != nil && got[0].Message.Text != want.Message.Text {
+				t.Errorf("текст сообщения %q, ожидался %q", got[0].Message.Text, want.Message.Text)
+			}
+			if want.CallbackQuery != nil && got[0].CallbackQuery.Data != want.CallbackQuery.Data {
+				t.Errorf("данные кнопки %q, ожидались %q", got[0].CallbackQuery.Data, want.CallbackQuery.Data)
+			}
+		})
+	}
+}